@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/health"
+	"gihub.com/gibiw/api-example/internal/jwtauth"
+	"gihub.com/gibiw/api-example/internal/metrics"
+	"gihub.com/gibiw/api-example/internal/outbox"
+	"gihub.com/gibiw/api-example/internal/purge"
+	"gihub.com/gibiw/api-example/internal/ratelimit"
 	"gihub.com/gibiw/api-example/internal/repository"
+	"gihub.com/gibiw/api-example/internal/tracing"
 	"gihub.com/gibiw/api-example/internal/transport/httpserver"
 	"gihub.com/gibiw/api-example/internal/usecases"
+	"gihub.com/gibiw/api-example/migrations"
 	"gihub.com/gibiw/api-example/pkg/database"
 	"github.com/gibiw/cache"
 	"github.com/gookit/slog"
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 // @title           Cars API
@@ -26,6 +46,15 @@ import (
 
 // @host      localhost:8080
 
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 JWT as "Bearer <token>"
+
 func main() {
 	var cfg config.Config
 	err := cleanenv.ReadConfig("config/config.yml", &cfg)
@@ -36,19 +65,194 @@ func main() {
 	slog.SetFormatter(slog.NewJSONFormatter())
 	slog.SetLogLevel(slog.LevelByName(cfg.LoggerCfg.Level))
 
-	db, err := database.Initialize(cfg.DBCfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.New(ctx, cfg.TracingCfg)
 	if err != nil {
-		slog.Fatal("can not initialize database", err)
+		slog.Fatal("can not initialize tracing", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("can not flush traces", err)
+		}
+	}()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	mtr := metrics.New(reg)
+
+	publisher := newPublisher(cfg.OutboxCfg)
+	if cfg.OutboxCfg.BatchSize < 1 || cfg.OutboxCfg.PollIntervalMillis <= 0 {
+		slog.Fatal("outbox batch size and poll interval must be positive")
+	}
+	pollInterval := time.Millisecond * time.Duration(cfg.OutboxCfg.PollIntervalMillis)
+
+	var (
+		db      *sqlx.DB
+		cars    *usecases.CarsUsecases
+		keys    *usecases.APIKeysUsecases
+		relay   *outbox.Relay
+		dbCheck []health.Check
+	)
+	switch cfg.DBCfg.Store {
+	case "postgres":
+		db, err = database.Initialize(cfg.DBCfg)
+		if err != nil {
+			slog.Fatal("can not initialize database", err)
+		}
+		defer db.Close()
+
+		schemaVersion, err := migrations.LatestVersion()
+		if err != nil {
+			slog.Fatal("can not read migrations", err)
+		}
+
+		txm := repository.NewTxManager(db,
+			repository.WithMaxAttempts(cfg.DBCfg.TxMaxAttempts),
+			repository.WithBackoff(time.Millisecond*time.Duration(cfg.DBCfg.TxRetryBackoffMillis)),
+		)
+		repo := repository.New(db)
+		cars = usecases.New(repo, txm)
+		relay = outbox.NewRelay(repo, publisher, cfg.OutboxCfg.BatchSize, pollInterval)
+		keys = usecases.NewAPIKeys(repository.NewAPIKeys(db))
+		reg.MustRegister(collectors.NewDBStatsCollector(db.DB, cfg.DBCfg.DatabaseName))
+		dbCheck = []health.Check{
+			{Name: "database", Checker: health.Ping(db)},
+			{Name: "migrations", Checker: health.MigrationVersion(func(ctx context.Context) (int64, error) {
+				return database.MigrationVersion(ctx, db)
+			}, schemaVersion)},
+		}
+	case "memory":
+		slog.Warn("storing data in memory, it is lost when the service stops")
+		repo := repository.NewMemory()
+		cars = usecases.New(repo, repo)
+		relay = outbox.NewRelay(repo, publisher, cfg.OutboxCfg.BatchSize, pollInterval)
+		keys = usecases.NewAPIKeys(repository.NewMemoryAPIKeys())
+	default:
+		slog.Fatal("unknown database store", cfg.DBCfg.Store)
 	}
 
-	repo := repository.New(db)
+	if cfg.AuthCfg.BootstrapAdminKey != "" {
+		if _, err := keys.EnsureAPIKey(ctx, "bootstrap", entities.RoleAdmin, cfg.AuthCfg.BootstrapAdminKey); err != nil {
+			slog.Fatal("can not store bootstrap admin key", err)
+		}
+	}
 
-	cache := cache.New()
-	ucs := usecases.New(repo)
-	srv := httpserver.New(cfg.ServiceCfg, ucs, cache)
+	ch := cache.New()
+	cacheTtl := time.Second * time.Duration(cfg.ServiceCfg.CacheTtlSeconds)
+	ucs := usecases.NewCached(cars, ch, cacheTtl, usecases.WithCacheObserver(mtr))
+	opts := []httpserver.Option{
+		httpserver.WithMetrics(mtr),
+		httpserver.WithAPIKeys(keys),
+		httpserver.WithReadinessChecks(dbCheck...),
+		httpserver.WithReadinessChecks(health.Check{Name: "cache", Checker: health.CacheProbe(ch)}),
+	}
+	if cfg.ServiceCfg.JWT.Enabled() {
+		tokens, err := jwtauth.New(cfg.ServiceCfg.JWT)
+		if err != nil {
+			slog.Fatal("can not initialize jwt validation", err)
+		}
+		opts = append(opts, httpserver.WithTokenValidator(tokens))
+	}
+	if len(cfg.ServiceCfg.TrustedProxies) > 0 {
+		opts = append(opts, trustedProxies(cfg.ServiceCfg.TrustedProxies))
+	}
+	if len(cfg.RateLimitCfg.Groups) > 0 {
+		opts = append(opts, rateLimits(cfg.RateLimitCfg, db))
+	}
+	// The workers use the database, they are waited for before it is
+	// closed.
+	var workers sync.WaitGroup
+	if cfg.PurgeCfg.RetentionSeconds > 0 {
+		if cfg.PurgeCfg.IntervalSeconds <= 0 {
+			slog.Fatal("purge interval must be positive")
+		}
+		worker := purge.New(cars,
+			time.Second*time.Duration(cfg.PurgeCfg.RetentionSeconds),
+			time.Second*time.Duration(cfg.PurgeCfg.IntervalSeconds),
+		)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.Run(ctx)
+		}()
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		relay.Run(ctx)
+	}()
 
-	err = srv.Run()
+	srv := httpserver.New(cfg.ServiceCfg, ucs, opts...)
+
+	err = srv.Run(ctx)
 	if err != nil {
-		slog.Fatal("can not start server", err)
+		slog.Fatal("can not run server", err)
+	}
+
+	stop()
+	workers.Wait()
+}
+
+func rateLimits(cfg config.RateLimit, db *sqlx.DB) httpserver.Option {
+	limits := make(map[string]ratelimit.Limit, len(cfg.Groups))
+	for group, l := range cfg.Groups {
+		if l.RequestsPerSecond <= 0 || l.Burst < 1 {
+			slog.Fatal("invalid rate limit of group", group)
+		}
+		limits[group] = ratelimit.Limit{Rate: l.RequestsPerSecond, Burst: l.Burst}
+	}
+
+	switch cfg.Store {
+	case "postgres":
+		if db == nil {
+			slog.Fatal("postgres rate limit store needs the postgres database store")
+		}
+		return httpserver.WithRateLimits(ratelimit.NewPostgresStore(db), limits)
+	case "memory":
+		return httpserver.WithRateLimits(ratelimit.NewMemoryStore(), limits)
+	default:
+		slog.Fatal("unknown rate limit store", cfg.Store)
+		return nil
+	}
+}
+
+// trustedProxies parses the proxy addresses, a single address is a range of
+// its own.
+func trustedProxies(addrs []string) httpserver.Option {
+	proxies := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				slog.Fatal("invalid trusted proxy", addr)
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		_, proxy, err := net.ParseCIDR(addr)
+		if err != nil {
+			slog.Fatal("invalid trusted proxy", addr)
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	return httpserver.WithTrustedProxies(proxies...)
+}
+
+func newPublisher(cfg config.Outbox) outbox.Publisher {
+	switch cfg.Publisher {
+	case "log":
+		return outbox.LogPublisher{}
+	case "memory":
+		return outbox.NewMemoryPublisher()
+	default:
+		slog.Fatal("unknown outbox publisher", cfg.Publisher)
+		return nil
 	}
 }
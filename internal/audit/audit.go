@@ -0,0 +1,25 @@
+// Package audit carries who is behind a request in a context, so the changes
+// made on its behalf can be recorded with it.
+package audit
+
+import "context"
+
+// Origin is the actor and the request a change is made by. Both are empty
+// for changes the service makes on its own, like purging deleted cars.
+type Origin struct {
+	Actor     string
+	RequestId string
+}
+
+type ctxKey struct{}
+
+// WithOrigin returns a context carrying o.
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, ctxKey{}, o)
+}
+
+// FromContext returns the origin stored in ctx or an empty one.
+func FromContext(ctx context.Context) Origin {
+	o, _ := ctx.Value(ctxKey{}).(Origin)
+	return o
+}
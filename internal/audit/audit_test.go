@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Run("with origin", func(t *testing.T) {
+		// Arrange
+		ctx := WithOrigin(context.Background(), Origin{Actor: "key-1", RequestId: "req-1"})
+
+		// Act
+		o := FromContext(ctx)
+
+		// Assert
+		assert.Equal(t, Origin{Actor: "key-1", RequestId: "req-1"}, o)
+	})
+
+	t.Run("without origin", func(t *testing.T) {
+		// Act
+		o := FromContext(context.Background())
+
+		// Assert
+		assert.Equal(t, Origin{}, o)
+	})
+}
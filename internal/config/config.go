@@ -1,25 +1,126 @@
 package config
 
 type Config struct {
-	ServiceCfg Service  `yaml:"service"`
-	DBCfg      Database `yaml:"database"`
-	LoggerCfg  Logger   `yaml:"logger"`
+	ServiceCfg   Service   `yaml:"service"`
+	DBCfg        Database  `yaml:"database"`
+	LoggerCfg    Logger    `yaml:"logger"`
+	TracingCfg   Tracing   `yaml:"tracing"`
+	AuthCfg      Auth      `yaml:"auth"`
+	RateLimitCfg RateLimit `yaml:"rateLimit"`
+	PurgeCfg     Purge     `yaml:"purge"`
+	OutboxCfg    Outbox    `yaml:"outbox"`
 }
 
 type Service struct {
-	Host            string `yaml:"host" env-default:"localhost"`
-	Port            string `yaml:"port" env-default:"80"`
-	CacheTtlSeconds int64  `yaml:"cacheTtlSeconds" env-default:"60"`
+	Host                    string `yaml:"host" env-default:"localhost"`
+	Port                    string `yaml:"port" env-default:"80"`
+	CacheTtlSeconds         int64  `yaml:"cacheTtlSeconds" env-default:"60"`
+	ReadTimeoutSeconds      int64  `yaml:"readTimeoutSeconds" env-default:"10"`
+	WriteTimeoutSeconds     int64  `yaml:"writeTimeoutSeconds" env-default:"30"`
+	IdleTimeoutSeconds      int64  `yaml:"idleTimeoutSeconds" env-default:"120"`
+	ShutdownTimeoutSeconds  int64  `yaml:"shutdownTimeoutSeconds" env-default:"30"`
+	ReadinessTimeoutSeconds int64  `yaml:"readinessTimeoutSeconds" env-default:"2"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in front
+	// of the service. Only requests from them may name the client address in
+	// the Forwarded or X-Forwarded-For header.
+	TrustedProxies []string `yaml:"trustedProxies" env:"TRUSTED_PROXIES"`
+	JWT            JWT      `yaml:"jwt"`
+	CORS           CORS     `yaml:"cors"`
 }
 
+// CORS configures which browser origins may call the API. Origins may
+// contain a wildcard, e.g. "https://*.example.com". With no origins
+// configured cross-origin requests are not allowed.
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string `yaml:"allowedMethods" env-default:"GET,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string `yaml:"allowedHeaders" env-default:"Accept,Authorization,Content-Type,If-Match,X-API-Key"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	MaxAgeSeconds    int64    `yaml:"maxAgeSeconds" env-default:"600"`
+}
+
+// JWT configures validation of bearer tokens. HS256 tokens are checked with
+// HMACSecret, RS256 and ES256 tokens with the keys of the JWKS read from
+// JWKSFile or JWKSURL, which is reloaded every JWKSRefreshSeconds.
+type JWT struct {
+	HMACSecret         string `yaml:"hmacSecret" env:"JWT_HMAC_SECRET"`
+	JWKSFile           string `yaml:"jwksFile"`
+	JWKSURL            string `yaml:"jwksUrl"`
+	JWKSRefreshSeconds int64  `yaml:"jwksRefreshSeconds" env-default:"300"`
+	Issuer             string `yaml:"issuer"`
+	Audience           string `yaml:"audience"`
+	LeewaySeconds      int64  `yaml:"leewaySeconds" env-default:"30"`
+}
+
+// Enabled reports whether any token signing key is configured.
+func (j JWT) Enabled() bool {
+	return j.HMACSecret != "" || j.JWKSFile != "" || j.JWKSURL != ""
+}
+
+// Database configures where the service stores its data. Store is postgres
+// or memory, which keeps everything in memory and needs no database; the
+// other fields only apply to postgres. Transactions failing on a
+// serialization failure or deadlock are run up to TxMaxAttempts times,
+// waiting TxRetryBackoffMillis before the first retry and twice as long
+// before every further one.
 type Database struct {
-	Host         string `yaml:"host" env-default:"localhost"`
-	Port         string `yaml:"port" env-default:"5432"`
-	DatabaseName string `yaml:"databaseName" env-default:"cars"`
-	User         string `yaml:"user" env-default:"postgres"`
-	Password     string `yaml:"password"`
+	Store                string `yaml:"store" env:"DATABASE_STORE" env-default:"postgres"`
+	Host                 string `yaml:"host" env-default:"localhost"`
+	Port                 string `yaml:"port" env-default:"5432"`
+	DatabaseName         string `yaml:"databaseName" env-default:"cars"`
+	User                 string `yaml:"user" env-default:"postgres"`
+	Password             string `yaml:"password"`
+	TxMaxAttempts        int    `yaml:"txMaxAttempts" env-default:"3"`
+	TxRetryBackoffMillis int64  `yaml:"txRetryBackoffMillis" env-default:"50"`
 }
 
 type Logger struct {
 	Level string `yaml:"level" env-default:"info"`
 }
+
+// Tracing configures OpenTelemetry. Exporter is one of none, stdout or otlp;
+// Endpoint is the host:port of an OTLP/HTTP collector.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env-default:"localhost:4318"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"serviceName" env-default:"api-example"`
+	SampleRatio float64 `yaml:"sampleRatio" env-default:"1"`
+}
+
+// Auth configures authentication. BootstrapAdminKey, if set, is stored as an
+// admin API key on start so the first keys can be created through the API.
+// It is a credential, set it through AUTH_BOOTSTRAP_ADMIN_KEY only.
+type Auth struct {
+	BootstrapAdminKey string `yaml:"bootstrapAdminKey" env:"AUTH_BOOTSTRAP_ADMIN_KEY"`
+}
+
+// RateLimit configures token buckets per route group (read, write, admin)
+// and per client IP (ip). Groups without an entry are not limited. Store is
+// memory or postgres.
+type RateLimit struct {
+	Store  string                    `yaml:"store" env-default:"memory"`
+	Groups map[string]RateLimitGroup `yaml:"groups"`
+}
+
+type RateLimitGroup struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int64   `yaml:"burst"`
+}
+
+// Purge configures the removal of deleted cars. Cars deleted longer than
+// RetentionSeconds ago are removed for good every IntervalSeconds. A
+// retention of 0 keeps deleted cars forever.
+type Purge struct {
+	RetentionSeconds int64 `yaml:"retentionSeconds" env-default:"2592000"`
+	IntervalSeconds  int64 `yaml:"intervalSeconds" env-default:"3600"`
+}
+
+// Outbox configures the relay of car events. Publisher is log or memory.
+// Up to BatchSize events are published at a time, the outbox is polled
+// every PollIntervalMillis.
+type Outbox struct {
+	Publisher          string `yaml:"publisher" env:"OUTBOX_PUBLISHER" env-default:"log"`
+	BatchSize          int    `yaml:"batchSize" env-default:"100"`
+	PollIntervalMillis int64  `yaml:"pollIntervalMillis" env-default:"1000"`
+}
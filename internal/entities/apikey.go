@@ -0,0 +1,73 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+func (r Role) IsValid() bool {
+	_, ok := roleScopes[r]
+	return ok
+}
+
+// APIKey is a stored API key. Only the SHA-256 hash of the secret is kept,
+// Prefix is its first characters so owners can tell their keys apart.
+type APIKey struct {
+	Id        uuid.UUID  `db:"id"`
+	Name      string     `db:"name"`
+	Prefix    string     `db:"prefix"`
+	Hash      string     `db:"key_hash"`
+	Role      Role       `db:"role"`
+	CreatedAt time.Time  `db:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// Scope is a permission a principal holds.
+type Scope string
+
+const (
+	ScopeCarsRead  Scope = "cars:read"
+	ScopeCarsWrite Scope = "cars:write"
+	// ScopeCarsAdmin allows to see and restore deleted cars and to see the
+	// history of changes.
+	ScopeCarsAdmin     Scope = "cars:admin"
+	ScopeAPIKeysManage Scope = "api-keys:manage"
+)
+
+var roleScopes = map[Role][]Scope{
+	RoleReader: {ScopeCarsRead},
+	RoleEditor: {ScopeCarsRead, ScopeCarsWrite},
+	RoleAdmin:  {ScopeCarsRead, ScopeCarsWrite, ScopeCarsAdmin, ScopeAPIKeysManage},
+}
+
+// Scopes returns the scopes granted by the role.
+func (r Role) Scopes() []Scope {
+	return append([]Scope(nil), roleScopes[r]...)
+}
+
+// Principal is the authenticated caller of a request, identified by an API
+// key or by the subject of a token.
+type Principal struct {
+	Id     string
+	Name   string
+	Scopes []Scope
+}
+
+func (p Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
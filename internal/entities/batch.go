@@ -0,0 +1,36 @@
+package entities
+
+// OperationKind is what an operation of a batch does to a car.
+type OperationKind string
+
+const (
+	OperationCreate OperationKind = "create"
+	OperationUpdate OperationKind = "update"
+	OperationDelete OperationKind = "delete"
+)
+
+func (k OperationKind) IsValid() bool {
+	return k == OperationCreate || k == OperationUpdate || k == OperationDelete
+}
+
+// CarOperation is one write of a batch. Updates and deletes apply to the
+// car with Car.Id and only if it still has Car.Version.
+type CarOperation struct {
+	Kind OperationKind
+	Car  Car
+}
+
+// CarOperationResult is the car written by an operation, or the error that
+// made the batch fail. Deletes return no car.
+type CarOperationResult struct {
+	Car Car
+	Err error
+}
+
+// BatchResult holds a result per operation run. A batch that is not
+// committed stops at the first failed operation, the operations after it
+// have no result.
+type BatchResult struct {
+	Committed bool
+	Results   []CarOperationResult
+}
@@ -1,11 +1,19 @@
 package entities
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// Car is a car listing. DeletedAt is set once the car is deleted, it is
+// kept until purged and can be restored until then.
 type Car struct {
-	Id    uuid.UUID `db:"id"`
-	Brand string    `db:"brand"`
-	Model string    `db:"model"`
-	Color string    `db:"color"`
-	Cost  uint64    `db:"cost"`
+	Id        uuid.UUID  `db:"id"`
+	Brand     string     `db:"brand"`
+	Model     string     `db:"model"`
+	Color     string     `db:"color"`
+	Cost      uint64     `db:"cost"`
+	Version   int64      `db:"version"`
+	DeletedAt *time.Time `db:"deleted_at"`
 }
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+)
+
+// Domain errors returned by the usecases and repositories. Implementations
+// wrap them with details, so they have to be checked with errors.Is.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	// ErrVersionMismatch is returned when a car has been changed since the
+	// version the caller based its write on.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrUnauthenticated is returned for missing, unknown or revoked
+	// credentials, ErrForbidden when the caller lacks a permission.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every invalid field of an entity. It matches
+// ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+
+	return ErrValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
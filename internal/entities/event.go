@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType names a change of a car announced to other systems.
+type EventType string
+
+const (
+	EventCarCreated  EventType = "CarCreated"
+	EventCarUpdated  EventType = "CarUpdated"
+	EventCarDeleted  EventType = "CarDeleted"
+	EventCarRestored EventType = "CarRestored"
+)
+
+// Event is a change of a car, Car is the car after it. Events may be
+// delivered more than once, Id tells duplicates apart and Car.Version orders
+// the events of a car.
+type Event struct {
+	Id         uuid.UUID
+	Type       EventType
+	Car        Car
+	OccurredAt time.Time
+}
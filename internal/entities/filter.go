@@ -0,0 +1,79 @@
+package entities
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+type SortField string
+
+const (
+	SortById    SortField = "id"
+	SortByBrand SortField = "brand"
+	SortByModel SortField = "model"
+	SortByColor SortField = "color"
+	SortByCost  SortField = "cost"
+)
+
+func (f SortField) IsValid() bool {
+	switch f {
+	case SortById, SortByBrand, SortByModel, SortByColor, SortByCost:
+		return true
+	}
+
+	return false
+}
+
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"
+	OrderDesc SortOrder = "desc"
+)
+
+func (o SortOrder) IsValid() bool {
+	return o == OrderAsc || o == OrderDesc
+}
+
+// Cursor is a keyset position: the sort value and id of the last car of a page.
+type Cursor struct {
+	Value string
+	Id    uuid.UUID
+}
+
+// CarFilter selects cars. Deleted cars are left out unless IncludeDeleted
+// is set.
+type CarFilter struct {
+	Brand          string
+	Model          string
+	Color          string
+	MinCost        *uint64
+	MaxCost        *uint64
+	SortBy         SortField
+	Order          SortOrder
+	Limit          uint64
+	After          *Cursor
+	IncludeDeleted bool
+}
+
+type CarsPage struct {
+	Cars []Car
+	Next *Cursor
+}
+
+// SortValue returns the value of the field the cars are sorted by.
+func (c Car) SortValue(f SortField) string {
+	switch f {
+	case SortByBrand:
+		return c.Brand
+	case SortByModel:
+		return c.Model
+	case SortByColor:
+		return c.Color
+	case SortByCost:
+		return strconv.FormatUint(c.Cost, 10)
+	default:
+		return c.Id.String()
+	}
+}
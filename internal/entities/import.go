@@ -0,0 +1,41 @@
+package entities
+
+// ImportMode decides what happens to the valid rows of an import when other
+// rows are rejected.
+type ImportMode string
+
+const (
+	// ImportPartial stores the valid rows and reports the rejected ones.
+	ImportPartial ImportMode = "partial"
+	// ImportAllOrNothing stores no row at all if any row is rejected.
+	ImportAllOrNothing ImportMode = "all-or-nothing"
+)
+
+func (m ImportMode) IsValid() bool {
+	return m == ImportPartial || m == ImportAllOrNothing
+}
+
+// ImportRow is a car read from an import. Row is the line of the file it was
+// read from, counting from 1. Err is set if the row could not be read into a
+// car.
+type ImportRow struct {
+	Row int
+	Car Car
+	Err error
+}
+
+// RejectedRow tells why a row was not imported. Fields lists the invalid
+// fields of a row that failed validation.
+type RejectedRow struct {
+	Row    int
+	Reason string
+	Fields []FieldError
+}
+
+// ImportReport is the outcome of an import. RolledBack is set when an
+// all-or-nothing import stored nothing because of rejected rows.
+type ImportReport struct {
+	Accepted   int
+	Rejected   []RejectedRow
+	RolledBack bool
+}
@@ -0,0 +1,91 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevisionOperation is the write that made a revision of a car.
+type RevisionOperation string
+
+const (
+	RevisionInsert  RevisionOperation = "insert"
+	RevisionUpdate  RevisionOperation = "update"
+	RevisionDelete  RevisionOperation = "delete"
+	RevisionRestore RevisionOperation = "restore"
+	RevisionPurge   RevisionOperation = "purge"
+)
+
+// CarValues are the fields of a car kept in its history.
+type CarValues struct {
+	Brand     string     `json:"brand"`
+	Model     string     `json:"model"`
+	Color     string     `json:"color"`
+	Cost      uint64     `json:"cost"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func (c Car) Values() CarValues {
+	return CarValues{
+		Brand:     c.Brand,
+		Model:     c.Model,
+		Color:     c.Color,
+		Cost:      c.Cost,
+		DeletedAt: c.DeletedAt,
+	}
+}
+
+// CarRevision is one change of a car. Revision is the version the change
+// gave the car, a purge counts as one more version. Old is nil for an
+// insert and New is nil for a purge. Actor and RequestId are empty for
+// changes the service made on its own.
+type CarRevision struct {
+	CarId     uuid.UUID
+	Revision  int64
+	Operation RevisionOperation
+	Old       *CarValues
+	New       *CarValues
+	Actor     string
+	RequestId string
+	ChangedAt time.Time
+}
+
+// FieldChange is the old and the new value of a field changed by a
+// revision. A value is nil if the car did not exist or is not deleted.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+var carValueFields = []string{"brand", "model", "color", "cost", "deleted_at"}
+
+// Diff returns the fields changed by the revision in the order of CarValues.
+func (r CarRevision) Diff() []FieldChange {
+	old, new := r.Old.fields(), r.New.fields()
+
+	changes := []FieldChange{}
+	for i, field := range carValueFields {
+		if old[i] != new[i] {
+			changes = append(changes, FieldChange{Field: field, Old: old[i], New: new[i]})
+		}
+	}
+
+	return changes
+}
+
+// fields returns the values in the order of carValueFields. Times are
+// formatted, so equal times compare equal whatever their location.
+func (v *CarValues) fields() []interface{} {
+	if v == nil {
+		return make([]interface{}, len(carValueFields))
+	}
+
+	var deletedAt interface{}
+	if v.DeletedAt != nil {
+		deletedAt = v.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return []interface{}{v.Brand, v.Model, v.Color, v.Cost, deletedAt}
+}
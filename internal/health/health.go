@@ -0,0 +1,157 @@
+// Package health checks whether the dependencies of the service are usable.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type Check struct {
+	Name    string
+	Checker Checker
+}
+
+type Result struct {
+	Status Status
+	// Err is why the check is down. It may carry driver messages, so it is
+	// meant for the logs and not for clients.
+	Err      error
+	Duration time.Duration
+}
+
+type Report struct {
+	Status Status
+	Checks map[string]Result
+}
+
+// Run executes all checks concurrently, each limited by timeout. The report
+// is up only if every check is up.
+func Run(ctx context.Context, timeout time.Duration, checks []Check) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+
+			res := run(ctx, timeout, c.Checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.Name] = res
+			if res.Status == StatusDown {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func run(ctx context.Context, timeout time.Duration, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusUp, Duration: time.Since(start)}
+	if err != nil {
+		res.Status = StatusDown
+		res.Err = err
+	}
+
+	return res
+}
+
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping checks that a database connection can be established.
+func Ping(p pinger) Checker {
+	return CheckerFunc(p.PingContext)
+}
+
+type cache interface {
+	Set(key string, value interface{}, ttl time.Duration)
+	Get(key string) (interface{}, error)
+	Delete(key string)
+}
+
+const cacheProbeKey = "health:probe"
+
+// cacheProbes numbers the probes, concurrent probes write their own keys.
+var cacheProbes atomic.Uint64
+
+// CacheProbe checks that a value written to the cache can be read back.
+func CacheProbe(c cache) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		probe := cacheProbes.Add(1)
+		key := fmt.Sprintf("%s:%d", cacheProbeKey, probe)
+		c.Set(key, probe, time.Minute)
+		defer c.Delete(key)
+
+		value, err := c.Get(key)
+		if err != nil {
+			return err
+		}
+		if value != probe {
+			return errors.New("cache returned another value than written")
+		}
+
+		return nil
+	})
+}
+
+// MigrationVersion checks that the database schema is at the expected version.
+func MigrationVersion(current func(ctx context.Context) (int64, error), expected int64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		version, err := current(ctx)
+		if err != nil {
+			return err
+		}
+		if version != expected {
+			return fmt.Errorf("schema version is %d, expected %d", version, expected)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mycache "github.com/gibiw/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	hanging := CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	t.Run("all checks up", func(t *testing.T) {
+		// Act
+		report := Run(context.Background(), time.Second, []Check{{Name: "a", Checker: up}, {Name: "b", Checker: up}})
+
+		// Assert
+		assert.Equal(t, StatusUp, report.Status)
+		assert.Len(t, report.Checks, 2)
+	})
+
+	t.Run("one check down", func(t *testing.T) {
+		// Act
+		report := Run(context.Background(), time.Second, []Check{{Name: "a", Checker: up}, {Name: "b", Checker: down}})
+
+		// Assert
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusUp, report.Checks["a"].Status)
+		assert.Equal(t, StatusDown, report.Checks["b"].Status)
+		assert.EqualError(t, report.Checks["b"].Err, "connection refused")
+	})
+
+	t.Run("check times out", func(t *testing.T) {
+		// Act
+		report := Run(context.Background(), 10*time.Millisecond, []Check{{Name: "a", Checker: hanging}})
+
+		// Assert
+		assert.Equal(t, StatusDown, report.Status)
+		assert.ErrorIs(t, report.Checks["a"].Err, context.DeadlineExceeded)
+	})
+
+	t.Run("without checks", func(t *testing.T) {
+		// Act
+		report := Run(context.Background(), time.Second, nil)
+
+		// Assert
+		assert.Equal(t, StatusUp, report.Status)
+	})
+}
+
+func TestCacheProbe(t *testing.T) {
+	t.Run("removes probe", func(t *testing.T) {
+		// Arrange
+		ch := mycache.New()
+
+		// Act
+		err := CacheProbe(ch).Check(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		_, err = ch.Get(fmt.Sprintf("%s:%d", cacheProbeKey, cacheProbes.Load()))
+		assert.ErrorIs(t, err, mycache.ErrorNotFound)
+	})
+
+	t.Run("concurrent probes", func(t *testing.T) {
+		// Arrange
+		ch := mycache.New()
+		probe := CacheProbe(ch)
+		errs := make(chan error, 50)
+
+		// Act
+		for i := 0; i < cap(errs); i++ {
+			go func() { errs <- probe.Check(context.Background()) }()
+		}
+
+		// Assert
+		for i := 0; i < cap(errs); i++ {
+			assert.NoError(t, <-errs)
+		}
+	})
+}
+
+func TestMigrationVersion(t *testing.T) {
+	current := func(v int64, err error) func(ctx context.Context) (int64, error) {
+		return func(ctx context.Context) (int64, error) { return v, err }
+	}
+
+	t.Run("expected version", func(t *testing.T) {
+		assert.NoError(t, MigrationVersion(current(3, nil), 3).Check(context.Background()))
+	})
+
+	t.Run("outdated version", func(t *testing.T) {
+		assert.EqualError(t, MigrationVersion(current(2, nil), 3).Check(context.Background()), "schema version is 2, expected 3")
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		returnErr := errors.New("relation goose_db_version does not exist")
+		assert.ErrorIs(t, MigrationVersion(current(0, returnErr), 3).Check(context.Background()), returnErr)
+	})
+}
@@ -0,0 +1,297 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/gookit/slog"
+)
+
+// minRefreshInterval limits reloads triggered by tokens with unknown key
+// ids, so forged tokens can not make us hammer the JWKS endpoint.
+const minRefreshInterval = 10 * time.Second
+
+// errKeysUnavailable is returned when the key set can not be loaded at all.
+// Unlike a bad token it is a server side problem.
+var errKeysUnavailable = errors.New("signing keys are unavailable")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet caches the public keys of a JWKS document and reloads them when
+// they get older than refresh or a token refers to an unknown key, which is
+// how rotated keys are picked up. Keys are loaded outside of mu, so a slow
+// source only delays the requests that can not do without new keys.
+type keySet struct {
+	load    func(ctx context.Context) ([]byte, error)
+	refresh time.Duration
+	now     func() time.Time
+
+	// loadMu lets one request load the keys at a time.
+	loadMu sync.Mutex
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	// failedAt and loadErr tell when and why loading the keys failed while
+	// there were none. Requests fail fast until minRefreshInterval passed,
+	// so an unavailable source is not asked on every request.
+	failedAt time.Time
+	loadErr  error
+}
+
+func fileSource(path string) func(ctx context.Context) ([]byte, error) {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+func urlSource(client *http.Client, url string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks endpoint answered %s", resp.Status)
+		}
+
+		return io.ReadAll(resp.Body)
+	}
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if err := s.unavailable(); err != nil {
+		return nil, err
+	}
+
+	keys, loadedAt := s.current()
+	age := s.now().Sub(loadedAt)
+	_, known := lookup(keys, kid)
+
+	var err error
+	switch {
+	case keys == nil:
+		err = s.reload(ctx, loadedAt, true)
+	case !known && age >= minRefreshInterval:
+		err = s.reload(ctx, loadedAt, true)
+	case age >= s.refresh:
+		// The current keys still serve while another request reloads them.
+		err = s.reload(ctx, loadedAt, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, _ = s.current()
+	key, ok := lookup(keys, kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (s *keySet) current() (map[string]crypto.PublicKey, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keys, s.loadedAt
+}
+
+// unavailable returns errKeysUnavailable while there are no keys and loading
+// them failed less than minRefreshInterval ago.
+func (s *keySet) unavailable() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil && s.loadErr != nil && s.now().Sub(s.failedAt) < minRefreshInterval {
+		return fmt.Errorf("%w: %s", errKeysUnavailable, s.loadErr)
+	}
+
+	return nil
+}
+
+// lookup finds the key by id. Tokens without a key id are accepted only
+// if the set has a single key.
+func lookup(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+
+	k, ok := keys[kid]
+	return k, ok
+}
+
+// reload replaces the keys loaded at seen, unless another request already
+// did. Without wait it gives up at once if another request is reloading.
+// If loading fails the previous keys are kept, so a flaky JWKS endpoint
+// does not lock everybody out.
+func (s *keySet) reload(ctx context.Context, seen time.Time, wait bool) error {
+	if !wait {
+		if !s.loadMu.TryLock() {
+			return nil
+		}
+	} else {
+		s.loadMu.Lock()
+	}
+	defer s.loadMu.Unlock()
+
+	if _, loadedAt := s.current(); !loadedAt.Equal(seen) {
+		return nil
+	}
+	// Requests that waited for a failed load do not load again.
+	if err := s.unavailable(); err != nil {
+		return err
+	}
+
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		if s.keys == nil {
+			s.failedAt = s.now()
+			s.loadErr = err
+			return fmt.Errorf("%w: %s", errKeysUnavailable, err)
+		}
+		logger.FromContext(ctx).Warn("can not reload jwks, keeping previous keys: ", err)
+		// Do not retry on every request while the source is failing.
+		s.loadedAt = s.now()
+		return nil
+	}
+
+	s.keys = keys
+	s.loadedAt = s.now()
+
+	return nil
+}
+
+func (s *keySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	data, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(ctx, data)
+}
+
+// parseJWKS parses the signing keys of a set. Keys that can not be parsed,
+// such as keys of unsupported types, are skipped so the others stay usable.
+func parseJWKS(ctx context.Context, data []byte) (map[string]crypto.PublicKey, error) {
+	set := jwks{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("can not parse jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJWK(k)
+		if err != nil {
+			logger.FromContext(ctx).WithFields(slog.M{"kid": k.Kid}).Warn("skipping jwks key: ", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parseJWK(k jwk) (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("rsa exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported curve %q", crv)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,133 @@
+// Package jwtauth validates JWT bearer tokens issued by the platform.
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const jwksTimeout = 5 * time.Second
+
+// claims are the registered claims plus the scopes, given either as a space
+// separated "scope" string (RFC 8693) or as a "scp" array.
+type claims struct {
+	jwt.RegisteredClaims
+	Name  string   `json:"name,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+}
+
+type Validator struct {
+	parser     *jwt.Parser
+	hmacSecret []byte
+	keys       *keySet
+	now        func() time.Time
+}
+
+func New(cfg config.JWT) (*Validator, error) {
+	v := &Validator{
+		hmacSecret: []byte(cfg.HMACSecret),
+		now:        time.Now,
+	}
+
+	methods := []string{}
+	if cfg.HMACSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	switch {
+	case cfg.JWKSFile != "" && cfg.JWKSURL != "":
+		return nil, errors.New("only one of jwks file and jwks url can be set")
+	case cfg.JWKSFile != "":
+		v.keys = v.newKeySet(fileSource(cfg.JWKSFile), cfg.JWKSRefreshSeconds)
+	case cfg.JWKSURL != "":
+		v.keys = v.newKeySet(urlSource(&http.Client{Timeout: jwksTimeout}, cfg.JWKSURL), cfg.JWKSRefreshSeconds)
+	}
+	if v.keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no token signing key is configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Second * time.Duration(cfg.LeewaySeconds)),
+		jwt.WithTimeFunc(func() time.Time { return v.now() }),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+func (v *Validator) newKeySet(load func(ctx context.Context) ([]byte, error), refreshSeconds int64) *keySet {
+	return &keySet{
+		load:    load,
+		refresh: time.Second * time.Duration(refreshSeconds),
+		now:     func() time.Time { return v.now() },
+	}
+}
+
+// Validate checks the signature and the exp, nbf, iss and aud claims of the
+// token and returns its subject and scopes. Invalid tokens are reported as
+// ErrUnauthenticated.
+func (v *Validator) Validate(ctx context.Context, token string) (entities.Principal, error) {
+	c := claims{}
+	_, err := v.parser.ParseWithClaims(token, &c, func(t *jwt.Token) (interface{}, error) {
+		return v.verificationKey(ctx, t)
+	})
+	if err != nil {
+		if errors.Is(err, errKeysUnavailable) {
+			return entities.Principal{}, err
+		}
+		return entities.Principal{}, fmt.Errorf("%w: %s", entities.ErrUnauthenticated, err)
+	}
+
+	if c.Subject == "" {
+		return entities.Principal{}, fmt.Errorf("%w: token has no subject", entities.ErrUnauthenticated)
+	}
+
+	return entities.Principal{Id: c.Subject, Name: c.Name, Scopes: c.scopes()}, nil
+}
+
+func (v *Validator) verificationKey(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	}
+
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
+
+func (c claims) scopes() []entities.Scope {
+	names := c.Scp
+	if c.Scope != "" {
+		names = append(names, strings.Fields(c.Scope)...)
+	}
+
+	scopes := make([]entities.Scope, 0, len(names))
+	for _, n := range names {
+		scopes = append(scopes, entities.Scope(n))
+	}
+
+	return scopes
+}
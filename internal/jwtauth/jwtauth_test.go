@@ -0,0 +1,338 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSecret   = "test-secret"
+	testIssuer   = "https://auth.example.com"
+	testAudience = "cars-api"
+)
+
+var testNow = time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func validClaims() claims {
+	return claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(testNow.Add(time.Hour)),
+			NotBefore: jwt.NewNumericDate(testNow.Add(-time.Minute)),
+		},
+		Scope: "cars:read cars:write",
+	}
+}
+
+func newTestValidator(t *testing.T, cfg config.JWT) *Validator {
+	cfg.Issuer = testIssuer
+	cfg.Audience = testAudience
+	v, err := New(cfg)
+	assert.NoError(t, err)
+	v.now = func() time.Time { return testNow }
+
+	return v
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, c claims) string {
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	return s
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encodeInt(key.N), E: encodeInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeInt(key.X), Y: encodeInt(key.Y)}
+}
+
+func marshalJWKS(t *testing.T, keys ...jwk) []byte {
+	data, err := json.Marshal(jwks{Keys: keys})
+	assert.NoError(t, err)
+
+	return data
+}
+
+func TestValidator_Validate(t *testing.T) {
+	t.Run("valid hs256 token", func(t *testing.T) {
+		// Arrange
+		v := newTestValidator(t, config.JWT{HMACSecret: testSecret})
+		token := sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), validClaims())
+
+		// Act
+		p, err := v.Validate(context.Background(), token)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Principal{
+			Id:     "user-1",
+			Scopes: []entities.Scope{entities.ScopeCarsRead, entities.ScopeCarsWrite},
+		}, p)
+	})
+
+	invalid := []struct {
+		name   string
+		change func(c *claims)
+	}{
+		{"expired token", func(c *claims) { c.ExpiresAt = jwt.NewNumericDate(testNow.Add(-time.Hour)) }},
+		{"token without expiry", func(c *claims) { c.ExpiresAt = nil }},
+		{"token not valid yet", func(c *claims) { c.NotBefore = jwt.NewNumericDate(testNow.Add(time.Hour)) }},
+		{"token for another audience", func(c *claims) { c.Audience = jwt.ClaimStrings{"billing"} }},
+		{"token of another issuer", func(c *claims) { c.Issuer = "https://evil.example.com" }},
+		{"token without subject", func(c *claims) { c.Subject = "" }},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			v := newTestValidator(t, config.JWT{HMACSecret: testSecret})
+			c := validClaims()
+			tt.change(&c)
+			token := sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), c)
+
+			// Act
+			_, err := v.Validate(context.Background(), token)
+
+			// Assert
+			assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+		})
+	}
+
+	t.Run("token with wrong signature", func(t *testing.T) {
+		// Arrange
+		v := newTestValidator(t, config.JWT{HMACSecret: testSecret})
+		token := sign(t, jwt.SigningMethodHS256, "", []byte("another-secret"), validClaims())
+
+		// Act
+		_, err := v.Validate(context.Background(), token)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+
+	t.Run("token with disallowed algorithm", func(t *testing.T) {
+		// Arrange
+		v := newTestValidator(t, config.JWT{HMACSecret: testSecret})
+		token := sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims())
+
+		// Act
+		_, err := v.Validate(context.Background(), token)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+
+	t.Run("rs256 token with rotated jwks file", func(t *testing.T) {
+		// Arrange
+		oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		assert.NoError(t, os.WriteFile(path, marshalJWKS(t, rsaJWK("old", oldKey)), 0o600))
+		v := newTestValidator(t, config.JWT{JWKSFile: path, JWKSRefreshSeconds: 300})
+
+		// Act
+		_, oldErr := v.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, validClaims()))
+		assert.NoError(t, os.WriteFile(path, marshalJWKS(t, rsaJWK("new", newKey)), 0o600))
+		_, earlyErr := v.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "new", newKey, validClaims()))
+		later := testNow.Add(minRefreshInterval)
+		v.now = func() time.Time { return later }
+		p, newErr := v.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "new", newKey, validClaims()))
+		_, retiredErr := v.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, validClaims()))
+
+		// Assert
+		assert.NoError(t, oldErr)
+		assert.ErrorIs(t, earlyErr, entities.ErrUnauthenticated)
+		assert.NoError(t, newErr)
+		assert.Equal(t, "user-1", p.Id)
+		assert.ErrorIs(t, retiredErr, entities.ErrUnauthenticated)
+	})
+
+	t.Run("es256 token with jwks url", func(t *testing.T) {
+		// Arrange
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		requests := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Write(marshalJWKS(t, ecJWK("ec-1", key)))
+		}))
+		defer srv.Close()
+		v := newTestValidator(t, config.JWT{JWKSURL: srv.URL, JWKSRefreshSeconds: 300})
+		c := validClaims()
+		c.Scope = ""
+		c.Scp = []string{"api-keys:manage"}
+
+		// Act
+		p, err := v.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-1", key, c))
+		_, againErr := v.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-1", key, c))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, againErr)
+		assert.Equal(t, []entities.Scope{entities.ScopeAPIKeysManage}, p.Scopes)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("jwks with unsupported key", func(t *testing.T) {
+		// Arrange
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		okp := jwk{Kty: "OKP", Kid: "ed-1", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+		unknownCurve := ecJWK("ec-2", key)
+		unknownCurve.Crv = "secp256k1"
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		assert.NoError(t, os.WriteFile(path, marshalJWKS(t, okp, unknownCurve, ecJWK("ec-1", key)), 0o600))
+		v := newTestValidator(t, config.JWT{JWKSFile: path, JWKSRefreshSeconds: 300})
+
+		// Act
+		_, err = v.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-1", key, validClaims()))
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("jwks url is unavailable", func(t *testing.T) {
+		// Arrange
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		v := newTestValidator(t, config.JWT{JWKSURL: srv.URL, JWKSRefreshSeconds: 300})
+
+		// Act
+		_, err = v.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-1", key, validClaims()))
+
+		// Assert
+		assert.ErrorIs(t, err, errKeysUnavailable)
+		assert.NotErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("without keys", func(t *testing.T) {
+		// Act
+		_, err := New(config.JWT{})
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("with jwks file and url", func(t *testing.T) {
+		// Act
+		_, err := New(config.JWT{JWKSFile: "jwks.json", JWKSURL: "http://localhost/jwks.json"})
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestKeySet_Reload(t *testing.T) {
+	// Arrange
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data := marshalJWKS(t, ecJWK("ec-1", key))
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	loads := 0
+	now := testNow
+	s := &keySet{
+		load: func(ctx context.Context) ([]byte, error) {
+			loads++
+			if loads > 1 {
+				close(loading)
+				<-release
+			}
+			return data, nil
+		},
+		refresh: time.Minute,
+		now:     func() time.Time { return now },
+	}
+	_, err = s.key(context.Background(), "ec-1")
+	assert.NoError(t, err)
+	now = now.Add(time.Minute)
+
+	// Act
+	reloaded := make(chan error)
+	go func() {
+		_, err := s.key(context.Background(), "ec-1")
+		reloaded <- err
+	}()
+	<-loading
+	_, whileLoadingErr := s.key(context.Background(), "ec-1")
+	close(release)
+
+	// Assert
+	assert.NoError(t, whileLoadingErr)
+	assert.NoError(t, <-reloaded)
+	assert.Equal(t, 2, loads)
+}
+
+func TestKeySet_LoadFailure(t *testing.T) {
+	// Arrange
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data := marshalJWKS(t, ecJWK("ec-1", key))
+	loads := 0
+	available := false
+	now := testNow
+	s := &keySet{
+		load: func(ctx context.Context) ([]byte, error) {
+			loads++
+			if !available {
+				return nil, errors.New("connection refused")
+			}
+			return data, nil
+		},
+		refresh: time.Minute,
+		now:     func() time.Time { return now },
+	}
+
+	// Act
+	_, firstErr := s.key(context.Background(), "ec-1")
+	_, secondErr := s.key(context.Background(), "ec-1")
+	loadsWhileFailing := loads
+	available = true
+	now = now.Add(minRefreshInterval)
+	_, recoveredErr := s.key(context.Background(), "ec-1")
+
+	// Assert
+	assert.ErrorIs(t, firstErr, errKeysUnavailable)
+	assert.ErrorIs(t, secondErr, errKeysUnavailable)
+	assert.Equal(t, 1, loadsWhileFailing)
+	assert.NoError(t, recoveredErr)
+	assert.Equal(t, 2, loads)
+}
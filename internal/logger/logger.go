@@ -0,0 +1,28 @@
+// Package logger carries a request-scoped slog record in a context, so every
+// log line of a request shares its request id and other fields.
+package logger
+
+import (
+	"context"
+
+	"github.com/gookit/slog"
+)
+
+type ctxKey struct{}
+
+// WithRecord returns a context carrying rec. The record must not be used for
+// logging directly, FromContext hands out copies of it.
+func WithRecord(ctx context.Context, rec *slog.Record) context.Context {
+	return context.WithValue(ctx, ctxKey{}, rec)
+}
+
+// FromContext returns a copy of the record stored in ctx or a record of the
+// standard logger. A copy is needed because a record keeps the time of its
+// first log line and is not safe for concurrent use.
+func FromContext(ctx context.Context) *slog.Record {
+	if rec, ok := ctx.Value(ctxKey{}).(*slog.Record); ok {
+		return rec.Copy()
+	}
+
+	return slog.WithFields(nil)
+}
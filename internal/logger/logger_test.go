@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gookit/slog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Run("with record", func(t *testing.T) {
+		// Arrange
+		buf := &bytes.Buffer{}
+		l := slog.NewJSONSugared(buf, slog.DebugLevel)
+		ctx := WithRecord(context.Background(), l.WithFields(slog.M{"request_id": "42"}))
+
+		// Act
+		FromContext(ctx).WithFields(slog.M{"car_id": "1"}).Info("car added")
+
+		// Assert
+		line := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "car added", line["message"])
+		assert.Equal(t, "42", line["request_id"])
+		assert.Equal(t, "1", line["car_id"])
+	})
+
+	t.Run("does not leak fields into the stored record", func(t *testing.T) {
+		// Arrange
+		rec := slog.WithFields(slog.M{"request_id": "42"})
+		ctx := WithRecord(context.Background(), rec)
+
+		// Act
+		FromContext(ctx).AddField("car_id", "1")
+
+		// Assert
+		assert.Nil(t, FromContext(ctx).Field("car_id"))
+		assert.Equal(t, "42", FromContext(ctx).Field("request_id"))
+	})
+
+	t.Run("without record", func(t *testing.T) {
+		assert.NotNil(t, FromContext(context.Background()))
+	})
+}
@@ -0,0 +1,114 @@
+// Package metrics exposes Prometheus metrics of the HTTP server and the
+// cars cache.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests no route matched, so arbitrary paths can not
+// blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+// abortedStatus labels requests whose handler aborted the response, e.g. by
+// panicking with http.ErrAbortHandler after the status was sent.
+const abortedStatus = "aborted"
+
+type Metrics struct {
+	gatherer       prometheus.Gatherer
+	requests       *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	cacheHits      prometheus.Counter
+	cacheMisses    prometheus.Counter
+	cacheEvictions prometheus.Counter
+}
+
+// New creates the metrics and registers them in reg.
+func New(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		gatherer: reg,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by route and status code, or aborted.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Number of cars served from the cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Number of cars not found in the cache.",
+		}),
+		cacheEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Number of expired cars removed from the cache.",
+		}),
+	}
+
+	reg.MustRegister(m.requests, m.duration, m.cacheHits, m.cacheMisses, m.cacheEvictions)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// Middleware counts requests and observes their latency labelled with the
+// chi route pattern rather than the raw path. Requests are counted while a
+// panic of the handler unwinds too, those are labelled aborted.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		completed := false
+
+		defer func() {
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			label := strconv.Itoa(status)
+			if !completed {
+				label = abortedStatus
+			}
+
+			m.requests.WithLabelValues(r.Method, route, label).Inc()
+			m.duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+		completed = true
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (m *Metrics) CacheHit() {
+	m.cacheHits.Inc()
+}
+
+func (m *Metrics) CacheMiss() {
+	m.cacheMisses.Inc()
+}
+
+func (m *Metrics) CacheEviction() {
+	m.cacheEvictions.Inc()
+}
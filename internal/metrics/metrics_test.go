@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	// Arrange
+	m := New(prometheus.NewRegistry())
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/cars/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// Act
+	for _, path := range []string{"/cars/1", "/cars/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Assert
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/cars/{id}", "404")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+}
+
+func TestMetrics_Middleware_Aborted(t *testing.T) {
+	// Arrange
+	m := New(prometheus.NewRegistry())
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/cars/export", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic(http.ErrAbortHandler)
+	})
+
+	// Act & Assert
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cars/export", nil))
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/cars/export", abortedStatus)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/cars/export", "200")))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	// Arrange
+	m := New(prometheus.NewRegistry())
+	m.CacheHit()
+	m.CacheMiss()
+	m.CacheMiss()
+	m.CacheEviction()
+	w := httptest.NewRecorder()
+
+	// Act
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.True(t, strings.Contains(body, "cache_hits_total 1"))
+	assert.True(t, strings.Contains(body, "cache_misses_total 2"))
+	assert.True(t, strings.Contains(body, "cache_evictions_total 1"))
+}
@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/gookit/slog"
+)
+
+// LogPublisher writes every event as a log line.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, events []entities.Event) error {
+	for _, e := range events {
+		logger.FromContext(ctx).WithFields(slog.M{
+			"event_id":    e.Id.String(),
+			"event_type":  string(e.Type),
+			"car_id":      e.Car.Id.String(),
+			"version":     e.Car.Version,
+			"occurred_at": e.OccurredAt.Format(time.RFC3339Nano),
+		}).Info("car event published")
+	}
+
+	return nil
+}
+
+// MemoryPublisher keeps the published events, e.g. for tests or to consume
+// them within the process.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []entities.Event
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(_ context.Context, events []entities.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, events...)
+
+	return nil
+}
+
+// Events returns the events published so far, oldest first.
+func (p *MemoryPublisher) Events() []entities.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]entities.Event(nil), p.events...)
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/google/uuid"
+	"github.com/gookit/slog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogPublisher_Publish(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := slog.NewJSONSugared(buf, slog.DebugLevel)
+	ctx := logger.WithRecord(context.Background(), l.WithFields(nil))
+	event := entities.Event{
+		Id:         uuid.MustParse("0b8e4f3c-2f4c-4d8e-9d6a-3a1f7f6c1e42"),
+		Type:       entities.EventCarDeleted,
+		Car:        entities.Car{Id: uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"), Version: 3},
+		OccurredAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	// Act
+	err := LogPublisher{}.Publish(ctx, []entities.Event{event})
+
+	// Assert
+	assert.NoError(t, err)
+	line := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "car event published", line["message"])
+	assert.Equal(t, "0b8e4f3c-2f4c-4d8e-9d6a-3a1f7f6c1e42", line["event_id"])
+	assert.Equal(t, "CarDeleted", line["event_type"])
+	assert.Equal(t, "bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", line["car_id"])
+	assert.Equal(t, float64(3), line["version"])
+}
+
+func TestMemoryPublisher_Publish(t *testing.T) {
+	// Arrange
+	p := NewMemoryPublisher()
+	first := entities.Event{Id: uuid.New(), Type: entities.EventCarCreated}
+	second := entities.Event{Id: uuid.New(), Type: entities.EventCarUpdated}
+
+	// Act
+	assert.NoError(t, p.Publish(context.Background(), []entities.Event{first}))
+	assert.NoError(t, p.Publish(context.Background(), []entities.Event{second}))
+
+	// Assert
+	assert.Equal(t, []entities.Event{first, second}, p.Events())
+}
@@ -0,0 +1,75 @@
+// Package outbox publishes the events stored with the changes of cars.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+)
+
+// Publisher delivers events to other systems. An event is only removed from
+// the outbox once Publish returned nil for it.
+type Publisher interface {
+	Publish(ctx context.Context, events []entities.Event) error
+}
+
+type store interface {
+	ProcessOutbox(ctx context.Context, limit int, fn func([]entities.Event) error) (int, error)
+}
+
+// Relay moves events from the outbox to a publisher, on start and then
+// every interval. Events are removed from the outbox after they were
+// published, so an event is published again if that fails: delivery is at
+// least once.
+type Relay struct {
+	s         store
+	p         Publisher
+	batchSize int
+	interval  time.Duration
+}
+
+func NewRelay(s store, p Publisher, batchSize int, interval time.Duration) *Relay {
+	return &Relay{
+		s:         s,
+		p:         p,
+		batchSize: batchSize,
+		interval:  interval,
+	}
+}
+
+// Run relays until the context is cancelled. A failed batch is logged and
+// tried again on the next tick.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.relay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes batches until the outbox is empty or a batch fails.
+func (r *Relay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.s.ProcessOutbox(ctx, r.batchSize, func(events []entities.Event) error {
+			return r.p.Publish(ctx, events)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.FromContext(ctx).Error("can not relay car events: ", err)
+			}
+			return
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubStore is an outbox holding events until they were processed.
+type stubStore struct {
+	mu     sync.Mutex
+	events []entities.Event
+	calls  int
+}
+
+func newStubStore(n int) *stubStore {
+	s := &stubStore{}
+	for i := 0; i < n; i++ {
+		s.events = append(s.events, entities.Event{Id: uuid.New(), Type: entities.EventCarCreated})
+	}
+	return s
+}
+
+func (s *stubStore) ProcessOutbox(_ context.Context, limit int, fn func([]entities.Event) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+
+	n := len(s.events)
+	if n > limit {
+		n = limit
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := fn(s.events[:n]); err != nil {
+		return 0, err
+	}
+	s.events = s.events[n:]
+
+	return n, nil
+}
+
+func (s *stubStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.events)
+}
+
+// flakyPublisher fails the given number of times before it publishes.
+type flakyPublisher struct {
+	*MemoryPublisher
+	mu       sync.Mutex
+	failures int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, events []entities.Event) error {
+	p.mu.Lock()
+	if p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return errors.New("broker down")
+	}
+	p.mu.Unlock()
+
+	return p.MemoryPublisher.Publish(ctx, events)
+}
+
+func TestRelay_Run(t *testing.T) {
+	t.Run("publishes all events in batches", func(t *testing.T) {
+		// Arrange
+		s := newStubStore(5)
+		p := NewMemoryPublisher()
+		r := NewRelay(s, p, 2, time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		// Act
+		go func() {
+			r.Run(ctx)
+			close(done)
+		}()
+		assert.Eventually(t, func() bool { return s.pending() == 0 }, time.Second, time.Millisecond)
+		cancel()
+		<-done
+
+		// Assert
+		assert.Len(t, p.Events(), 5)
+		assert.Equal(t, 3, s.calls)
+	})
+
+	t.Run("publishes events again after a failure", func(t *testing.T) {
+		// Arrange
+		s := newStubStore(1)
+		p := &flakyPublisher{MemoryPublisher: NewMemoryPublisher(), failures: 2}
+		r := NewRelay(s, p, 10, 5*time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		// Act
+		go func() {
+			r.Run(ctx)
+			close(done)
+		}()
+		assert.Eventually(t, func() bool { return s.pending() == 0 }, time.Second, time.Millisecond)
+		cancel()
+		<-done
+
+		// Assert
+		assert.Len(t, p.Events(), 1)
+	})
+}
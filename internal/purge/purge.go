@@ -0,0 +1,55 @@
+// Package purge removes deleted cars for good once their retention period
+// is over.
+package purge
+
+import (
+	"context"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/logger"
+)
+
+type purger interface {
+	PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Worker purges the cars deleted longer than the retention ago, once on
+// start and then every interval.
+type Worker struct {
+	p         purger
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+}
+
+func New(p purger, retention, interval time.Duration) *Worker {
+	return &Worker{
+		p:         p,
+		retention: retention,
+		interval:  interval,
+		now:       time.Now,
+	}
+}
+
+// Run purges until the context is cancelled. A failed purge is logged and
+// tried again on the next tick.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) purge(ctx context.Context) {
+	if _, err := w.p.PurgeDeletedCars(ctx, w.now().Add(-w.retention)); err != nil && ctx.Err() == nil {
+		logger.FromContext(ctx).Error("can not purge deleted cars: ", err)
+	}
+}
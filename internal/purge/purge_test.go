@@ -0,0 +1,79 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubPurger struct {
+	mu     sync.Mutex
+	before []time.Time
+	err    error
+}
+
+func (p *stubPurger) PurgeDeletedCars(_ context.Context, before time.Time) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.before = append(p.before, before)
+
+	return 1, p.err
+}
+
+func (p *stubPurger) calls() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]time.Time(nil), p.before...)
+}
+
+func TestWorker_Run(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	t.Run("purges on start and on every tick", func(t *testing.T) {
+		// Arrange
+		p := &stubPurger{}
+		w := New(p, 24*time.Hour, 10*time.Millisecond)
+		w.now = func() time.Time { return now }
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		// Act
+		go func() {
+			w.Run(ctx)
+			close(done)
+		}()
+		time.Sleep(35 * time.Millisecond)
+		cancel()
+		<-done
+
+		// Assert
+		calls := p.calls()
+		assert.GreaterOrEqual(t, len(calls), 2)
+		assert.Equal(t, now.Add(-24*time.Hour), calls[0])
+	})
+
+	t.Run("keeps running after a failed purge", func(t *testing.T) {
+		// Arrange
+		p := &stubPurger{err: errors.New("connection refused")}
+		w := New(p, time.Hour, 10*time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		// Act
+		go func() {
+			w.Run(ctx)
+			close(done)
+		}()
+		time.Sleep(25 * time.Millisecond)
+		cancel()
+		<-done
+
+		// Assert
+		assert.GreaterOrEqual(t, len(p.calls()), 2)
+	})
+}
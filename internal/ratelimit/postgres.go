@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// lockBucketQuery creates the row of a full bucket unless there is one
+	// and returns the bucket with its row locked. The no-op update of an
+	// existing row takes the lock, so a sweep can not delete the row before
+	// it is read.
+	lockBucketQuery = "INSERT INTO rate_limits (key, tokens, updated_at, full_at) VALUES ($1, $2, $3, $3) " +
+		"ON CONFLICT (key) DO UPDATE SET key=EXCLUDED.key RETURNING tokens, updated_at"
+	updateBucketQuery = "UPDATE rate_limits SET tokens=$1, updated_at=$2, full_at=$3 WHERE key=$4"
+	sweepBucketsQuery = "DELETE FROM rate_limits WHERE full_at <= $1"
+)
+
+// PostgresStore keeps the buckets in the rate_limits table, so every
+// instance of the service draws from the same buckets. The row of a bucket
+// is locked while a token is taken. Rows of full buckets are deleted every
+// sweepInterval.
+type PostgresStore struct {
+	db  *sqlx.DB
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *sqlx.DB) *PostgresStore {
+	return &PostgresStore{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	if s.sweepDue(now) {
+		if _, err := s.db.ExecContext(ctx, sweepBucketsQuery, now); err != nil {
+			logger.FromContext(ctx).Warn("can not delete full rate limit buckets: ", err)
+		}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	full := fullBucket(limit, now)
+	b := bucket{}
+	if err := tx.QueryRowxContext(ctx, lockBucketQuery, key, full.tokens, full.updated).Scan(&b.tokens, &b.updated); err != nil {
+		return Result{}, err
+	}
+
+	next, res := take(b, limit, now)
+	if _, err := tx.ExecContext(ctx, updateBucketQuery, next.tokens, next.updated, now.Add(res.Reset), key); err != nil {
+		return Result{}, err
+	}
+
+	return res, tx.Commit()
+}
+
+// sweepDue tells whether full buckets should be deleted now. A failed
+// sweep is not retried before the next interval.
+func (s *PostgresStore) sweepDue(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return false
+	}
+	s.lastSweep = now
+
+	return true
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresStore_Take(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 5}
+
+	expectTake := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(lockBucketQuery)).
+			WithArgs("read:principal:user-1", float64(5), now).
+			WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.5, now.Add(-time.Second)))
+		mock.ExpectExec(regexp.QuoteMeta(updateBucketQuery)).
+			WithArgs(0.5, now, now.Add(4500*time.Millisecond), "read:principal:user-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	t.Run("takes token", func(t *testing.T) {
+		// Arrange
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+		s := NewPostgresStore(sqlx.NewDb(mockDB, "sqlmock"))
+		s.now = func() time.Time { return now }
+		s.lastSweep = now
+		expectTake(mock)
+
+		// Act
+		res, err := s.Take(context.Background(), "read:principal:user-1", limit)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Allowed: true, Limit: 5, Remaining: 0, Reset: 4500 * time.Millisecond}, res)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("deletes full buckets", func(t *testing.T) {
+		// Arrange
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+		s := NewPostgresStore(sqlx.NewDb(mockDB, "sqlmock"))
+		s.now = func() time.Time { return now }
+		s.lastSweep = now.Add(-sweepInterval)
+		mock.ExpectExec(regexp.QuoteMeta(sweepBucketsQuery)).
+			WithArgs(now).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		expectTake(mock)
+
+		// Act
+		_, err := s.Take(context.Background(), "read:principal:user-1", limit)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, now, s.lastSweep)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
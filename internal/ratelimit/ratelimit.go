@@ -0,0 +1,61 @@
+// Package ratelimit implements token bucket rate limiting with buckets kept
+// in memory or in Postgres.
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit lets Burst requests through at once and refills the bucket with
+// Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int64
+}
+
+// Result is the outcome of taking a token. Reset is how long it takes the
+// bucket to fill up again, RetryAfter how long to wait for the next token
+// when the request was not allowed.
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func fullBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Burst), updated: now}
+}
+
+// take refills the bucket for the time passed since it was last updated and
+// takes a token from it if there is one.
+func take(b bucket, limit Limit, now time.Time) (bucket, Result) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens := math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+
+	res := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	res.Remaining = int64(math.Floor(tokens))
+	res.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+
+	return bucket{tokens: tokens, updated: now}, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 2, Burst: 4}
+
+	tests := []struct {
+		name   string
+		bucket bucket
+		tokens float64
+		result Result
+	}{
+		{
+			name:   "full bucket",
+			bucket: fullBucket(limit, now),
+			tokens: 3,
+			result: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:   "empty bucket",
+			bucket: bucket{tokens: 0.5, updated: now},
+			tokens: 0.5,
+			result: Result{Limit: 4, Remaining: 0, Reset: 1750 * time.Millisecond, RetryAfter: 250 * time.Millisecond},
+		},
+		{
+			name:   "refilled bucket",
+			bucket: bucket{tokens: 0, updated: now.Add(-time.Second)},
+			tokens: 1,
+			result: Result{Allowed: true, Limit: 4, Remaining: 1, Reset: 1500 * time.Millisecond},
+		},
+		{
+			name:   "refill stops at burst",
+			bucket: bucket{tokens: 0, updated: now.Add(-time.Hour)},
+			tokens: 3,
+			result: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			b, res := take(tt.bucket, limit, now)
+
+			// Assert
+			assert.Equal(t, tt.result, res)
+			assert.Equal(t, tt.tokens, b.tokens)
+			assert.Equal(t, now, b.updated)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	// Arrange
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	// Act
+	first, _ := s.Take(context.Background(), "a", limit)
+	second, _ := s.Take(context.Background(), "a", limit)
+	third, _ := s.Take(context.Background(), "a", limit)
+	other, _ := s.Take(context.Background(), "b", limit)
+	now = now.Add(time.Second)
+	refilled, _ := s.Take(context.Background(), "a", limit)
+
+	// Assert
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Second, third.RetryAfter)
+	assert.True(t, other.Allowed)
+	assert.True(t, refilled.Allowed)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	// Arrange
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+	s.Take(context.Background(), "a", limit)
+
+	// Act
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "b", limit)
+
+	// Assert
+	assert.NotContains(t, s.buckets, "a")
+	assert.Contains(t, s.buckets, "b")
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	getAPIKeysQuery      = "SELECT id, name, prefix, key_hash, role, created_at, revoked_at FROM api_keys ORDER BY created_at, id"
+	getAPIKeyByHashQuery = "SELECT id, name, prefix, key_hash, role, created_at, revoked_at FROM api_keys WHERE key_hash=$1"
+	addAPIKeyQuery       = "INSERT INTO api_keys (name, prefix, key_hash, role) VALUES ($1, $2, $3, $4) RETURNING id, name, prefix, key_hash, role, created_at, revoked_at"
+	revokeAPIKeyQuery    = "UPDATE api_keys SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL"
+	apiKeyExistsQuery    = "SELECT EXISTS (SELECT 1 FROM api_keys WHERE id=$1)"
+)
+
+type APIKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeys(db *sqlx.DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+func (r *APIKeyRepository) GetAPIKeys(ctx context.Context) (_ []entities.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetAPIKeys", "SELECT", getAPIKeysQuery)
+	defer tracing.End(span, &err)
+
+	keys := []entities.APIKey{}
+	if err = conn(ctx, r.db).SelectContext(ctx, &keys, getAPIKeysQuery); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (_ entities.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetAPIKeyByHash", "SELECT", getAPIKeyByHashQuery)
+	defer tracing.End(span, &err)
+
+	key := entities.APIKey{}
+	if err = conn(ctx, r.db).GetContext(ctx, &key, getAPIKeyByHashQuery, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.APIKey{}, fmt.Errorf("api key: %w", entities.ErrNotFound)
+		}
+		return entities.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) AddAPIKey(ctx context.Context, key entities.APIKey) (_ entities.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.AddAPIKey", "INSERT", addAPIKeyQuery)
+	defer tracing.End(span, &err)
+
+	newKey := entities.APIKey{}
+	err = conn(ctx, r.db).QueryRowxContext(ctx, addAPIKeyQuery, key.Name, key.Prefix, key.Hash, key.Role).StructScan(&newKey)
+	if err != nil {
+		return entities.APIKey{}, mapError(ctx, err)
+	}
+
+	return newKey, nil
+}
+
+// RevokeAPIKey marks the key as revoked. Revoking a revoked key again keeps
+// the original revocation time and succeeds.
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.RevokeAPIKey", "UPDATE", revokeAPIKeyQuery)
+	defer tracing.End(span, &err)
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, revokeAPIKeyQuery, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err = conn(ctx, r.db).GetContext(ctx, &exists, apiKeyExistsQuery, id); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("api key with id %s: %w", id, entities.ErrNotFound)
+	}
+
+	return nil
+}
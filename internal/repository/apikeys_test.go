@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyColumns = []string{"id", "name", "prefix", "key_hash", "role", "created_at", "revoked_at"}
+
+func TestAPIKeyRepository_GetAPIKeyByHash(t *testing.T) {
+	t.Run("with key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+		createdAt := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(apiKeyColumns).
+			AddRow(id.String(), "importer", "ak_AQEBAQEB", "hash", "editor", createdAt, nil)
+
+		f.mock.ExpectQuery(regexp.QuoteMeta(getAPIKeyByHashQuery)).
+			WithArgs("hash").
+			WillReturnRows(rows)
+		repo := NewAPIKeys(f.db)
+
+		// Act
+		key, err := repo.GetAPIKeyByHash(context.Background(), "hash")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.APIKey{
+			Id:        id,
+			Name:      "importer",
+			Prefix:    "ak_AQEBAQEB",
+			Hash:      "hash",
+			Role:      entities.RoleEditor,
+			CreatedAt: createdAt,
+		}, key)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectQuery(regexp.QuoteMeta(getAPIKeyByHashQuery)).
+			WithArgs("hash").
+			WillReturnError(sql.ErrNoRows)
+		repo := NewAPIKeys(f.db)
+
+		// Act
+		_, err := repo.GetAPIKeyByHash(context.Background(), "hash")
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+}
+
+func TestAPIKeyRepository_AddAPIKey(t *testing.T) {
+	// Arrange
+	f := NewFixture(t)
+	defer f.Teardown()
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	createdAt := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	key := entities.APIKey{Name: "importer", Prefix: "ak_AQEBAQEB", Hash: "hash", Role: entities.RoleEditor}
+	rows := sqlmock.NewRows(apiKeyColumns).
+		AddRow(id.String(), "importer", "ak_AQEBAQEB", "hash", "editor", createdAt, nil)
+
+	f.mock.ExpectQuery(regexp.QuoteMeta(addAPIKeyQuery)).
+		WithArgs("importer", "ak_AQEBAQEB", "hash", entities.RoleEditor).
+		WillReturnRows(rows)
+	repo := NewAPIKeys(f.db)
+
+	// Act
+	newKey, err := repo.AddAPIKey(context.Background(), key)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, id, newKey.Id)
+	assert.Equal(t, createdAt, newKey.CreatedAt)
+}
+
+func TestAPIKeyRepository_RevokeAPIKey(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		id := uuid.New()
+
+		f.mock.ExpectExec(regexp.QuoteMeta(revokeAPIKeyQuery)).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		repo := NewAPIKeys(f.db)
+
+		// Act
+		err := repo.RevokeAPIKey(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("already revoked", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		id := uuid.New()
+
+		f.mock.ExpectExec(regexp.QuoteMeta(revokeAPIKeyQuery)).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectQuery(regexp.QuoteMeta(apiKeyExistsQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		repo := NewAPIKeys(f.db)
+
+		// Act
+		err := repo.RevokeAPIKey(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		id := uuid.New()
+
+		f.mock.ExpectExec(regexp.QuoteMeta(revokeAPIKeyQuery)).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectQuery(regexp.QuoteMeta(apiKeyExistsQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		repo := NewAPIKeys(f.db)
+
+		// Act
+		err := repo.RevokeAPIKey(context.Background(), id)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+}
@@ -0,0 +1,560 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/audit"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// carStore is what the usecases need from a car repository, including its
+// transactions.
+type carStore interface {
+	GetCars(ctx context.Context, filter entities.CarFilter) ([]entities.Car, error)
+	ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error
+	GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error)
+	AddCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	ImportCars(ctx context.Context, batches [][]entities.Car) error
+	DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error
+	UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error)
+	PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error)
+	GetCarRevisions(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error)
+	GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error)
+	ProcessOutbox(ctx context.Context, limit int, fn func([]entities.Event) error) (int, error)
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+func TestMemoryCarRepository_Conformance(t *testing.T) {
+	testCarStoreConformance(t, func(t *testing.T) carStore {
+		return NewMemory()
+	})
+}
+
+// TestCarRepository_Conformance needs a migrated Postgres database given by
+// TEST_DATABASE_DSN, e.g. the one of deploy/docker-compose.yml. The cars,
+// car_revisions and outbox_events tables are emptied before every test.
+func TestCarRepository_Conformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testCarStoreConformance(t, func(t *testing.T) carStore {
+		if _, err := db.Exec("TRUNCATE cars, car_revisions, outbox_events"); err != nil {
+			t.Fatal(err)
+		}
+		return struct {
+			*CarRepository
+			*TxManager
+		}{New(db), NewTxManager(db)}
+	})
+}
+
+func testCarStoreConformance(t *testing.T, newStore func(t *testing.T) carStore) {
+	ctx := context.Background()
+	audi := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+	bmw := entities.Car{Brand: "BMW", Model: "X5", Color: "Black", Cost: 30000}
+	fiat := entities.Car{Brand: "Fiat", Model: "Panda", Color: "Red", Cost: 8000}
+
+	add := func(t *testing.T, s carStore, cars ...entities.Car) []entities.Car {
+		added := make([]entities.Car, 0, len(cars))
+		for _, car := range cars {
+			newCar, err := s.AddCar(ctx, car)
+			assert.NoError(t, err)
+			added = append(added, newCar)
+		}
+		return added
+	}
+
+	t.Run("add and get car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+
+		// Act
+		car, err := s.AddCar(ctx, audi)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, car.Id)
+		assert.Equal(t, int64(1), car.Version)
+		got, err := s.GetCarById(ctx, car.Id, false)
+		assert.NoError(t, err)
+		assert.Equal(t, car, got)
+	})
+
+	t.Run("add car with too long brand", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := audi
+		car.Brand = strings.Repeat("a", 51)
+
+		// Act
+		_, err := s.AddCar(ctx, car)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrValidation)
+	})
+
+	t.Run("get unknown car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+
+		// Act
+		_, err := s.GetCarById(ctx, uuid.New(), true)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("update car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+		car.Cost = 9500
+
+		// Act
+		updated, err := s.UpdateCar(ctx, car)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Version)
+		assert.Equal(t, uint64(9500), updated.Cost)
+		_, err = s.UpdateCar(ctx, car)
+		assert.ErrorIs(t, err, entities.ErrVersionMismatch)
+		car.Id = uuid.New()
+		_, err = s.UpdateCar(ctx, car)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("delete car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+
+		// Act
+		stale := s.DeleteCarById(ctx, car.Id, car.Version+1)
+		err := s.DeleteCarById(ctx, car.Id, car.Version)
+
+		// Assert
+		assert.ErrorIs(t, stale, entities.ErrVersionMismatch)
+		assert.NoError(t, err)
+		_, err = s.GetCarById(ctx, car.Id, false)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		err = s.DeleteCarById(ctx, car.Id, car.Version+1)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		car.Version++
+		_, err = s.UpdateCar(ctx, car)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		deleted, err := s.GetCarById(ctx, car.Id, true)
+		assert.NoError(t, err)
+		assert.NotNil(t, deleted.DeletedAt)
+		assert.Equal(t, car.Version, deleted.Version)
+	})
+
+	t.Run("list deleted cars", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		cars := add(t, s, audi, bmw)
+		assert.NoError(t, s.DeleteCarById(ctx, cars[1].Id, cars[1].Version))
+
+		// Act
+		live, err := s.GetCars(ctx, entities.CarFilter{SortBy: entities.SortByCost})
+		assert.NoError(t, err)
+		all, err := s.GetCars(ctx, entities.CarFilter{SortBy: entities.SortByCost, IncludeDeleted: true})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Car{cars[0]}, live)
+		assert.Len(t, all, 2)
+		assert.NotNil(t, all[1].DeletedAt)
+	})
+
+	t.Run("restore car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+		assert.NoError(t, s.DeleteCarById(ctx, car.Id, car.Version))
+
+		// Act
+		stale, staleErr := s.RestoreCar(ctx, car.Id, car.Version)
+		restored, err := s.RestoreCar(ctx, car.Id, car.Version+1)
+
+		// Assert
+		assert.ErrorIs(t, staleErr, entities.ErrVersionMismatch)
+		assert.Equal(t, entities.Car{}, stale)
+		assert.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, car.Version+2, restored.Version)
+		_, err = s.GetCarById(ctx, car.Id, false)
+		assert.NoError(t, err)
+		_, err = s.RestoreCar(ctx, car.Id, restored.Version)
+		assert.ErrorIs(t, err, entities.ErrConflict)
+		_, err = s.RestoreCar(ctx, uuid.New(), 1)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("purge deleted cars", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		cars := add(t, s, audi, bmw)
+		assert.NoError(t, s.DeleteCarById(ctx, cars[0].Id, cars[0].Version))
+
+		// Act
+		none, err := s.PurgeDeletedCars(ctx, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		n, err := s.PurgeDeletedCars(ctx, time.Now().Add(time.Hour))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), none)
+		assert.Equal(t, int64(1), n)
+		_, err = s.GetCarById(ctx, cars[0].Id, true)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		_, err = s.GetCarById(ctx, cars[1].Id, false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("history of car", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		ctx := audit.WithOrigin(ctx, audit.Origin{Actor: "key-1", RequestId: "req-1"})
+		car, err := s.AddCar(ctx, audi)
+		assert.NoError(t, err)
+		car.Cost = 9500
+		car, err = s.UpdateCar(ctx, car)
+		assert.NoError(t, err)
+		assert.NoError(t, s.DeleteCarById(ctx, car.Id, car.Version))
+		_, err = s.RestoreCar(ctx, car.Id, car.Version+1)
+		assert.NoError(t, err)
+		assert.NoError(t, s.DeleteCarById(ctx, car.Id, car.Version+2))
+		_, err = s.PurgeDeletedCars(context.Background(), time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+
+		// Act
+		revisions, err := s.GetCarRevisions(ctx, car.Id)
+
+		// Assert
+		assert.NoError(t, err)
+		operations := []entities.RevisionOperation{}
+		for i, rev := range revisions {
+			assert.Equal(t, int64(i+1), rev.Revision)
+			operations = append(operations, rev.Operation)
+		}
+		assert.Equal(t, []entities.RevisionOperation{
+			entities.RevisionInsert, entities.RevisionUpdate, entities.RevisionDelete,
+			entities.RevisionRestore, entities.RevisionDelete, entities.RevisionPurge,
+		}, operations)
+		assert.Equal(t, []entities.FieldChange{{Field: "cost", Old: uint64(10000), New: uint64(9500)}}, revisions[1].Diff())
+		assert.Equal(t, "key-1", revisions[1].Actor)
+		assert.Equal(t, "req-1", revisions[1].RequestId)
+		assert.Empty(t, revisions[5].Actor)
+		assert.Nil(t, revisions[5].New)
+		rev, err := s.GetCarRevision(ctx, car.Id, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, revisions[1], rev)
+		_, err = s.GetCarRevision(ctx, car.Id, 7)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("rolled back transaction records no history", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		var id uuid.UUID
+
+		// Act
+		err := s.RunInTx(ctx, func(ctx context.Context) error {
+			car, err := s.AddCar(ctx, audi)
+			id = car.Id
+			if err != nil {
+				return err
+			}
+			return errors.New("stop")
+		})
+
+		// Assert
+		assert.Error(t, err)
+		revisions, err := s.GetCarRevisions(ctx, id)
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+
+	t.Run("outbox announces changes in order", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+		car.Cost = 9500
+		car, err := s.UpdateCar(ctx, car)
+		assert.NoError(t, err)
+		assert.NoError(t, s.DeleteCarById(ctx, car.Id, car.Version))
+		_, err = s.RestoreCar(ctx, car.Id, car.Version+1)
+		assert.NoError(t, err)
+		_, err = s.PurgeDeletedCars(ctx, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.NoError(t, s.ImportCars(ctx, [][]entities.Car{{bmw}}))
+		published := []entities.Event{}
+
+		// Act
+		first, err := s.ProcessOutbox(ctx, 3, func(events []entities.Event) error {
+			published = append(published, events...)
+			return nil
+		})
+		assert.NoError(t, err)
+		second, err := s.ProcessOutbox(ctx, 3, func(events []entities.Event) error {
+			published = append(published, events...)
+			return nil
+		})
+		assert.NoError(t, err)
+		last, err := s.ProcessOutbox(ctx, 3, func(events []entities.Event) error {
+			published = append(published, events...)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3, 2, 0}, []int{first, second, last})
+		types := []entities.EventType{}
+		for _, e := range published {
+			types = append(types, e.Type)
+		}
+		assert.Equal(t, []entities.EventType{
+			entities.EventCarCreated, entities.EventCarUpdated, entities.EventCarDeleted,
+			entities.EventCarRestored, entities.EventCarCreated,
+		}, types)
+		assert.Equal(t, car.Id, published[2].Car.Id)
+		assert.Equal(t, car.Version+1, published[2].Car.Version)
+		assert.NotNil(t, published[2].Car.DeletedAt)
+		assert.Equal(t, car.Id, published[3].Car.Id)
+		assert.Equal(t, car.Version+2, published[3].Car.Version)
+		assert.Nil(t, published[3].Car.DeletedAt)
+		assert.Equal(t, "BMW", published[4].Car.Brand)
+		assert.NotEqual(t, published[0].Id, published[1].Id)
+	})
+
+	t.Run("outbox is usable while events are published", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		add(t, s, audi)
+		var nested int
+		var nestedErr, addErr error
+
+		// Act
+		n, err := s.ProcessOutbox(ctx, 10, func(events []entities.Event) error {
+			nested, nestedErr = s.ProcessOutbox(ctx, 10, func([]entities.Event) error { return nil })
+			_, addErr = s.AddCar(ctx, bmw)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.NoError(t, nestedErr)
+		assert.Equal(t, 0, nested)
+		assert.NoError(t, addErr)
+		remaining := []entities.Event{}
+		_, err = s.ProcessOutbox(ctx, 10, func(events []entities.Event) error {
+			remaining = append(remaining, events...)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, remaining, 1)
+		assert.Equal(t, "BMW", remaining[0].Car.Brand)
+	})
+
+	t.Run("outbox keeps events that were not published", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		add(t, s, audi)
+		expectedErr := errors.New("broker down")
+
+		// Act
+		n, err := s.ProcessOutbox(ctx, 10, func([]entities.Event) error {
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.Equal(t, 0, n)
+		n, err = s.ProcessOutbox(ctx, 10, func([]entities.Event) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("rolled back transaction announces nothing", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+
+		// Act
+		err := s.RunInTx(ctx, func(ctx context.Context) error {
+			if _, err := s.AddCar(ctx, audi); err != nil {
+				return err
+			}
+			return errors.New("stop")
+		})
+
+		// Assert
+		assert.Error(t, err)
+		n, err := s.ProcessOutbox(ctx, 10, func([]entities.Event) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("get cars filtered, sorted and paged", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		cars := add(t, s, audi, bmw, fiat)
+		minCost := uint64(9000)
+		filter := entities.CarFilter{MinCost: &minCost, SortBy: entities.SortByCost, Order: entities.OrderDesc, Limit: 1}
+
+		// Act
+		first, err := s.GetCars(ctx, filter)
+		assert.NoError(t, err)
+		filter.After = &entities.Cursor{Value: first[0].SortValue(entities.SortByCost), Id: first[0].Id}
+		second, err := s.GetCars(ctx, filter)
+		assert.NoError(t, err)
+		filter.After = &entities.Cursor{Value: second[0].SortValue(entities.SortByCost), Id: second[0].Id}
+		last, err := s.GetCars(ctx, filter)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Car{cars[1]}, first)
+		assert.Equal(t, []entities.Car{cars[0]}, second)
+		assert.Empty(t, last)
+		red, err := s.GetCars(ctx, entities.CarFilter{Color: "Red", SortBy: entities.SortByBrand})
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Car{cars[0], cars[2]}, red)
+	})
+
+	t.Run("export cars", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		cars := add(t, s, audi, bmw, fiat)
+		exported := []entities.Car{}
+
+		// Act
+		err := s.ExportCars(ctx, entities.CarFilter{SortBy: entities.SortByCost}, func(c entities.Car) error {
+			exported = append(exported, c)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Car{cars[2], cars[0], cars[1]}, exported)
+	})
+
+	t.Run("import cars", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+
+		// Act
+		err := s.ImportCars(ctx, [][]entities.Car{{audi, bmw}, {fiat}})
+
+		// Assert
+		assert.NoError(t, err)
+		cars, err := s.GetCars(ctx, entities.CarFilter{SortBy: entities.SortByCost})
+		assert.NoError(t, err)
+		assert.Len(t, cars, 3)
+	})
+
+	t.Run("rolled back transaction stores nothing", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+		expectedErr := errors.New("stop")
+
+		// Act
+		err := s.RunInTx(ctx, func(ctx context.Context) error {
+			if _, err := s.AddCar(ctx, bmw); err != nil {
+				return err
+			}
+			if err := s.DeleteCarById(ctx, car.Id, car.Version); err != nil {
+				return err
+			}
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+		cars, err := s.GetCars(ctx, entities.CarFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Car{car}, cars)
+	})
+
+	t.Run("committed transaction stores all", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+
+		// Act
+		err := s.RunInTx(ctx, func(ctx context.Context) error {
+			for _, car := range []entities.Car{audi, bmw} {
+				if _, err := s.AddCar(ctx, car); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		cars, err := s.GetCars(ctx, entities.CarFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, cars, 2)
+	})
+
+	t.Run("reads outside transaction see committed cars", func(t *testing.T) {
+		// Arrange
+		s := newStore(t)
+		car := add(t, s, audi)[0]
+		update := car
+		update.Cost = 12000
+		var added entities.Car
+		var outside, inside []entities.Car
+		var outsideCar entities.Car
+		var outsideHistory []entities.CarRevision
+		var outsideAddedErr error
+
+		// Act
+		err := s.RunInTx(ctx, func(txCtx context.Context) error {
+			var err error
+			if added, err = s.AddCar(txCtx, bmw); err != nil {
+				return err
+			}
+			if _, err = s.UpdateCar(txCtx, update); err != nil {
+				return err
+			}
+			_, outsideAddedErr = s.GetCarById(ctx, added.Id, false)
+			outsideCar, _ = s.GetCarById(ctx, car.Id, false)
+			outsideHistory, _ = s.GetCarRevisions(ctx, car.Id)
+			outside, _ = s.GetCars(ctx, entities.CarFilter{})
+			inside, _ = s.GetCars(txCtx, entities.CarFilter{})
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.ErrorIs(t, outsideAddedErr, entities.ErrNotFound)
+		assert.Equal(t, car, outsideCar)
+		assert.Len(t, outsideHistory, 1)
+		assert.Equal(t, []entities.Car{car}, outside)
+		assert.Len(t, inside, 2)
+		_, err = s.GetCarById(ctx, added.Id, false)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,529 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gihub.com/gibiw/api-example/internal/audit"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+)
+
+// maxCarFieldLength is the length of the varchar columns of the cars table.
+const maxCarFieldLength = 50
+
+type memoryTxKey struct{}
+
+// MemoryCarRepository keeps cars, their history and their outbox in memory
+// with the semantics of CarRepository. It is meant for development and tests, the
+// cars are lost when the service stops. It runs its own transactions, see
+// RunInTx.
+type MemoryCarRepository struct {
+	mu        sync.RWMutex
+	cars      map[uuid.UUID]entities.Car
+	revisions map[uuid.UUID][]entities.CarRevision
+	events    []entities.Event
+	// publishing holds the ids of the events passed to a ProcessOutbox fn.
+	publishing map[uuid.UUID]struct{}
+	// committed is the state before the running transaction, reads outside
+	// of the transaction see it. It is nil without a transaction.
+	committed *memoryState
+	// txMu is held by a transaction and by every write outside of one, so a
+	// rolled back transaction can restore the cars it started with.
+	txMu sync.Mutex
+}
+
+// memoryState is the state of a MemoryCarRepository at the start of a
+// transaction.
+type memoryState struct {
+	cars      map[uuid.UUID]entities.Car
+	revisions map[uuid.UUID][]entities.CarRevision
+	events    []entities.Event
+}
+
+func NewMemory() *MemoryCarRepository {
+	return &MemoryCarRepository{
+		cars:       map[uuid.UUID]entities.Car{},
+		revisions:  map[uuid.UUID][]entities.CarRevision{},
+		publishing: map[uuid.UUID]struct{}{},
+	}
+}
+
+func (r *MemoryCarRepository) GetCars(ctx context.Context, filter entities.CarFilter) ([]entities.Car, error) {
+	cars, err := r.selectCars(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && uint64(len(cars)) > filter.Limit {
+		cars = cars[:filter.Limit]
+	}
+
+	return cars, nil
+}
+
+// ExportCars calls fn for every car matching the filter, in the order of the
+// filter. Like a cursor it sees the cars as they were when it started.
+func (r *MemoryCarRepository) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error {
+	cars, err := r.selectCars(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, car := range cars {
+		if err := fn(car); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryCarRepository) selectCars(ctx context.Context, filter entities.CarFilter) ([]entities.Car, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = entities.SortById
+	}
+	if !sortBy.IsValid() {
+		return nil, fmt.Errorf("unknown sort field %q", sortBy)
+	}
+	desc := filter.Order == entities.OrderDesc
+
+	r.mu.RLock()
+	stored, _ := r.view(ctx)
+	cars := make([]entities.Car, 0, len(stored))
+	for _, car := range stored {
+		if matchesFilter(car, filter, sortBy) {
+			cars = append(cars, car)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(cars, func(i, j int) bool {
+		c := compareCar(cars[i], sortBy, cars[j].SortValue(sortBy), cars[j].Id)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	return cars, nil
+}
+
+func matchesFilter(car entities.Car, filter entities.CarFilter, sortBy entities.SortField) bool {
+	switch {
+	case !filter.IncludeDeleted && car.DeletedAt != nil,
+		filter.Brand != "" && car.Brand != filter.Brand,
+		filter.Model != "" && car.Model != filter.Model,
+		filter.Color != "" && car.Color != filter.Color,
+		filter.MinCost != nil && car.Cost < *filter.MinCost,
+		filter.MaxCost != nil && car.Cost > *filter.MaxCost:
+		return false
+	case filter.After == nil:
+		return true
+	}
+
+	c := compareCar(car, sortBy, filter.After.Value, filter.After.Id)
+	if filter.Order == entities.OrderDesc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// compareCar compares the sort value and id of the car with the given ones
+// the way Postgres compares the (column, id) row values.
+func compareCar(car entities.Car, sortBy entities.SortField, value string, id uuid.UUID) int {
+	if sortBy != entities.SortById {
+		if c := compareSortValues(sortBy, car.SortValue(sortBy), value); c != 0 {
+			return c
+		}
+	}
+
+	return bytes.Compare(car.Id[:], id[:])
+}
+
+func compareSortValues(sortBy entities.SortField, a, b string) int {
+	if sortBy == entities.SortByCost {
+		x, errX := strconv.ParseUint(a, 10, 64)
+		y, errY := strconv.ParseUint(b, 10, 64)
+		if errX == nil && errY == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(a, b)
+}
+
+// GetCarById returns the car with the id. A deleted car is only returned
+// with includeDeleted.
+func (r *MemoryCarRepository) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cars, _ := r.view(ctx)
+	car, ok := cars[id]
+	if !ok || !includeDeleted && car.DeletedAt != nil {
+		return entities.Car{}, carNotFound(id)
+	}
+
+	return car, nil
+}
+
+func (r *MemoryCarRepository) AddCar(ctx context.Context, car entities.Car) (newCar entities.Car, err error) {
+	err = r.write(ctx, func() error {
+		newCar, err = r.insert(ctx, car)
+		return err
+	})
+
+	return newCar, err
+}
+
+// ImportCars stores all cars or none.
+func (r *MemoryCarRepository) ImportCars(ctx context.Context, batches [][]entities.Car) error {
+	for _, batch := range batches {
+		for _, car := range batch {
+			if err := checkCar(car); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.write(ctx, func() error {
+		for _, batch := range batches {
+			for _, car := range batch {
+				if _, err := r.insert(ctx, car); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCarById marks the car as deleted only if it still has the given
+// version. The car is kept until it is purged.
+func (r *MemoryCarRepository) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error {
+	return r.write(ctx, func() error {
+		old, err := r.current(id, version)
+		if err != nil {
+			return err
+		}
+		car := old
+		now := time.Now()
+		car.DeletedAt = &now
+		car.Version++
+		r.cars[id] = car
+		r.recordChange(ctx, entities.RevisionDelete, &old, &car)
+		return nil
+	})
+}
+
+// UpdateCar updates the car only if it still has car.Version and returns it
+// with the incremented version.
+func (r *MemoryCarRepository) UpdateCar(ctx context.Context, car entities.Car) (newCar entities.Car, err error) {
+	if err := checkCar(car); err != nil {
+		return entities.Car{}, err
+	}
+
+	err = r.write(ctx, func() error {
+		old, err := r.current(car.Id, car.Version)
+		if err != nil {
+			return err
+		}
+		car.DeletedAt = nil
+		car.Version++
+		r.cars[car.Id] = car
+		r.recordChange(ctx, entities.RevisionUpdate, &old, &car)
+		newCar = car
+		return nil
+	})
+
+	return newCar, err
+}
+
+// RestoreCar undoes the delete of the car only if it still has the given
+// version and returns it with the incremented version.
+func (r *MemoryCarRepository) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (car entities.Car, err error) {
+	err = r.write(ctx, func() error {
+		old, ok := r.cars[id]
+		if !ok {
+			return carNotFound(id)
+		}
+		if err := checkRestorable(old, version); err != nil {
+			return err
+		}
+		car = old
+		car.DeletedAt = nil
+		car.Version++
+		r.cars[id] = car
+		r.recordChange(ctx, entities.RevisionRestore, &old, &car)
+		return nil
+	})
+	if err != nil {
+		return entities.Car{}, err
+	}
+
+	return car, nil
+}
+
+// PurgeDeletedCars removes the cars deleted before the given time for good
+// and returns how many were removed. Their history is kept.
+func (r *MemoryCarRepository) PurgeDeletedCars(ctx context.Context, before time.Time) (n int64, err error) {
+	err = r.write(ctx, func() error {
+		for id, car := range r.cars {
+			if car.DeletedAt != nil && car.DeletedAt.Before(before) {
+				delete(r.cars, id)
+				r.recordChange(ctx, entities.RevisionPurge, &car, nil)
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// RunInTx runs fn in a transaction. Transactions run one at a time. Until
+// fn returns, reads outside of the transaction see the state before it. If
+// fn returns an error the cars, their history and the outbox are restored to
+// that state. A call within a transaction joins it.
+func (r *MemoryCarRepository) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	// Revisions are only appended and events only appended or replaced by a
+	// copy, so the slices are kept as they are.
+	r.mu.Lock()
+	committed := &memoryState{
+		cars:      make(map[uuid.UUID]entities.Car, len(r.cars)),
+		revisions: make(map[uuid.UUID][]entities.CarRevision, len(r.revisions)),
+		events:    r.events,
+	}
+	for id, car := range r.cars {
+		committed.cars[id] = car
+	}
+	for id, revs := range r.revisions {
+		committed.revisions[id] = revs
+	}
+	r.committed = committed
+	r.mu.Unlock()
+
+	err := fn(context.WithValue(ctx, memoryTxKey{}, r))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.committed = nil
+	if err != nil {
+		r.cars = committed.cars
+		r.revisions = committed.revisions
+		r.events = committed.events
+	}
+
+	return err
+}
+
+func (r *MemoryCarRepository) inTx(ctx context.Context) bool {
+	tx, ok := ctx.Value(memoryTxKey{}).(*MemoryCarRepository)
+	return ok && tx == r
+}
+
+// view returns the cars and their history as a read in ctx sees them. The
+// cars must be locked.
+func (r *MemoryCarRepository) view(ctx context.Context) (map[uuid.UUID]entities.Car, map[uuid.UUID][]entities.CarRevision) {
+	if r.committed != nil && !r.inTx(ctx) {
+		return r.committed.cars, r.committed.revisions
+	}
+
+	return r.cars, r.revisions
+}
+
+// write runs fn with the cars locked for writing. Outside of a transaction
+// it waits for running transactions to finish first.
+func (r *MemoryCarRepository) write(ctx context.Context, fn func() error) error {
+	if !r.inTx(ctx) {
+		r.txMu.Lock()
+		defer r.txMu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn()
+}
+
+// insert stores the car with a new id. The cars must be locked for writing.
+func (r *MemoryCarRepository) insert(ctx context.Context, car entities.Car) (entities.Car, error) {
+	if err := checkCar(car); err != nil {
+		return entities.Car{}, err
+	}
+
+	car.Id = uuid.New()
+	car.Version = 1
+	car.DeletedAt = nil
+	r.cars[car.Id] = car
+	r.recordChange(ctx, entities.RevisionInsert, nil, &car)
+
+	return car, nil
+}
+
+// recordChange records the write of a car from old to new in its history
+// and the outbox. The cars must be locked for writing.
+func (r *MemoryCarRepository) recordChange(ctx context.Context, op entities.RevisionOperation, old, new *entities.Car) {
+	r.addRevision(ctx, op, old, new)
+
+	if typ, ok := eventTypes[op]; ok {
+		r.events = append(r.events, entities.Event{
+			Id:         uuid.New(),
+			Type:       typ,
+			Car:        *new,
+			OccurredAt: time.Now(),
+		})
+	}
+}
+
+// ProcessOutbox passes up to limit of the oldest events to fn and removes
+// them once fn returns nil. fn runs without the lock, so slow publishing does
+// not hold up the store. Like locked rows, the events passed to fn are
+// skipped by other calls meanwhile. It returns how many events were
+// processed.
+func (r *MemoryCarRepository) ProcessOutbox(ctx context.Context, limit int, fn func([]entities.Event) error) (int, error) {
+	var batch []entities.Event
+	r.write(ctx, func() error {
+		for _, e := range r.events {
+			if len(batch) == limit {
+				break
+			}
+			if _, ok := r.publishing[e.Id]; !ok {
+				r.publishing[e.Id] = struct{}{}
+				batch = append(batch, e)
+			}
+		}
+		return nil
+	})
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	err := fn(append([]entities.Event(nil), batch...))
+
+	r.write(ctx, func() error {
+		for _, e := range batch {
+			delete(r.publishing, e.Id)
+		}
+		if err == nil {
+			r.events = withoutEvents(r.events, batch)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(batch), nil
+}
+
+// withoutEvents returns a copy of events without the removed ones.
+func withoutEvents(events, removed []entities.Event) []entities.Event {
+	ids := make(map[uuid.UUID]struct{}, len(removed))
+	for _, e := range removed {
+		ids[e.Id] = struct{}{}
+	}
+
+	kept := make([]entities.Event, 0, len(events))
+	for _, e := range events {
+		if _, ok := ids[e.Id]; !ok {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
+
+// addRevision records the change of a car from old to new. A purged car has
+// no new state, its revision is the version after old.
+func (r *MemoryCarRepository) addRevision(ctx context.Context, op entities.RevisionOperation, old, new *entities.Car) {
+	origin := audit.FromContext(ctx)
+	rev := entities.CarRevision{
+		Operation: op,
+		Actor:     origin.Actor,
+		RequestId: origin.RequestId,
+		ChangedAt: time.Now(),
+	}
+	if old != nil {
+		v := old.Values()
+		rev.CarId, rev.Revision, rev.Old = old.Id, old.Version+1, &v
+	}
+	if new != nil {
+		v := new.Values()
+		rev.CarId, rev.Revision, rev.New = new.Id, new.Version, &v
+	}
+
+	r.revisions[rev.CarId] = append(r.revisions[rev.CarId], rev)
+}
+
+// GetCarRevisions returns the history of the car, oldest change first. It is
+// kept after the car is purged.
+func (r *MemoryCarRepository) GetCarRevisions(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revisions := r.view(ctx)
+	return append([]entities.CarRevision{}, revisions[id]...), nil
+}
+
+func (r *MemoryCarRepository) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revisions := r.view(ctx)
+	for _, rev := range revisions[id] {
+		if rev.Revision == revision {
+			return rev, nil
+		}
+	}
+
+	return entities.CarRevision{}, revisionNotFound(id, revision)
+}
+
+// current returns the car if it is not deleted and has the given version.
+// The cars must be locked.
+func (r *MemoryCarRepository) current(id uuid.UUID, version int64) (entities.Car, error) {
+	car, ok := r.cars[id]
+	if !ok {
+		return entities.Car{}, carNotFound(id)
+	}
+	if err := checkWritable(car, version); err != nil {
+		return entities.Car{}, err
+	}
+
+	return car, nil
+}
+
+// checkCar applies the constraints of the cars table.
+func checkCar(car entities.Car) error {
+	for _, v := range []string{car.Brand, car.Model, car.Color} {
+		if utf8.RuneCountInString(v) > maxCarFieldLength {
+			return fmt.Errorf("%w: value is too long", entities.ErrValidation)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+)
+
+// MemoryAPIKeyRepository keeps API keys in memory with the semantics of
+// APIKeyRepository, so the service can run without a database.
+type MemoryAPIKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[uuid.UUID]entities.APIKey
+}
+
+func NewMemoryAPIKeys() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{
+		keys: map[uuid.UUID]entities.APIKey{},
+	}
+}
+
+func (r *MemoryAPIKeyRepository) GetAPIKeys(_ context.Context) ([]entities.APIKey, error) {
+	r.mu.RLock()
+	keys := make([]entities.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].Id.String() < keys[j].Id.String()
+	})
+
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) GetAPIKeyByHash(_ context.Context, hash string) (entities.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return entities.APIKey{}, fmt.Errorf("api key: %w", entities.ErrNotFound)
+}
+
+func (r *MemoryAPIKeyRepository) AddAPIKey(_ context.Context, key entities.APIKey) (entities.APIKey, error) {
+	if !key.Role.IsValid() {
+		return entities.APIKey{}, fmt.Errorf("%w: invalid role %q", entities.ErrValidation, key.Role)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if k.Hash == key.Hash {
+			return entities.APIKey{}, fmt.Errorf("%w: api key already exists", entities.ErrConflict)
+		}
+	}
+
+	key.Id = uuid.New()
+	key.CreatedAt = time.Now()
+	key.RevokedAt = nil
+	r.keys[key.Id] = key
+
+	return key, nil
+}
+
+// RevokeAPIKey marks the key as revoked. Revoking a revoked key again keeps
+// the original revocation time and succeeds.
+func (r *MemoryAPIKeyRepository) RevokeAPIKey(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return fmt.Errorf("api key with id %s: %w", id, entities.ErrNotFound)
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		r.keys[id] = key
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	addEventQuery = "INSERT INTO outbox_events (type, car_id, payload) VALUES ($1, $2, $3)"
+	// Locked events are skipped, so several relays can share the outbox.
+	fetchEventsQuery  = "SELECT seq, id, type, payload, occurred_at FROM outbox_events ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED"
+	deleteEventsQuery = "DELETE FROM outbox_events WHERE seq = ANY($1)"
+
+	// carPayloadJSON builds the carPayload of a row in SQL, for the
+	// statements that add the events of many cars at once.
+	carPayloadJSON = "jsonb_build_object('id', id, 'brand', brand, 'model', model, 'color', color, 'cost', cost, 'version', version, 'deleted_at', deleted_at)"
+)
+
+// eventTypes are the events announcing the writes of cars. Purges are not
+// announced, the cars were announced as deleted before.
+var eventTypes = map[entities.RevisionOperation]entities.EventType{
+	entities.RevisionInsert:  entities.EventCarCreated,
+	entities.RevisionUpdate:  entities.EventCarUpdated,
+	entities.RevisionDelete:  entities.EventCarDeleted,
+	entities.RevisionRestore: entities.EventCarRestored,
+}
+
+// carPayload is the car an event is stored with.
+type carPayload struct {
+	Id        uuid.UUID  `json:"id"`
+	Brand     string     `json:"brand"`
+	Model     string     `json:"model"`
+	Color     string     `json:"color"`
+	Cost      uint64     `json:"cost"`
+	Version   int64      `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type eventRow struct {
+	Seq        int64     `db:"seq"`
+	Id         uuid.UUID `db:"id"`
+	Type       string    `db:"type"`
+	Payload    []byte    `db:"payload"`
+	OccurredAt time.Time `db:"occurred_at"`
+}
+
+func (row eventRow) toEvent() (entities.Event, error) {
+	p := carPayload{}
+	if err := json.Unmarshal(row.Payload, &p); err != nil {
+		return entities.Event{}, fmt.Errorf("decode event %s: %w", row.Id, err)
+	}
+
+	return entities.Event{
+		Id:   row.Id,
+		Type: entities.EventType(row.Type),
+		Car: entities.Car{
+			Id:        p.Id,
+			Brand:     p.Brand,
+			Model:     p.Model,
+			Color:     p.Color,
+			Cost:      p.Cost,
+			Version:   p.Version,
+			DeletedAt: p.DeletedAt,
+		},
+		OccurredAt: row.OccurredAt,
+	}, nil
+}
+
+// recordChange records the write of a car from old to new in its history
+// and the outbox. It has to run in the transaction of the write.
+func (r *CarRepository) recordChange(ctx context.Context, op entities.RevisionOperation, old, new *entities.Car) error {
+	if err := r.addRevision(ctx, op, old, new); err != nil {
+		return err
+	}
+
+	typ, ok := eventTypes[op]
+	if !ok {
+		return nil
+	}
+
+	payload, err := json.Marshal(carPayload{
+		Id:        new.Id,
+		Brand:     new.Brand,
+		Model:     new.Model,
+		Color:     new.Color,
+		Cost:      new.Cost,
+		Version:   new.Version,
+		DeletedAt: new.DeletedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, addEventQuery, string(typ), new.Id, string(payload))
+	return err
+}
+
+// ProcessOutbox passes up to limit of the oldest events to fn and removes
+// them once fn returns nil. The events stay locked meanwhile. It returns how
+// many events were processed.
+func (r *CarRepository) ProcessOutbox(ctx context.Context, limit int, fn func([]entities.Event) error) (n int, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.ProcessOutbox", "SELECT", fetchEventsQuery)
+	defer tracing.End(span, &err)
+
+	err = r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		rows := []eventRow{}
+		if err := conn(ctx, r.db).SelectContext(ctx, &rows, fetchEventsQuery, limit); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		events := make([]entities.Event, 0, len(rows))
+		seqs := make([]int64, 0, len(rows))
+		for _, row := range rows {
+			event, err := row.toEvent()
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+			seqs = append(seqs, row.Seq)
+		}
+
+		if err := fn(events); err != nil {
+			return err
+		}
+		if _, err := conn(ctx, r.db).ExecContext(ctx, deleteEventsQuery, pq.Array(seqs)); err != nil {
+			return err
+		}
+		n = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarRepository_ProcessOutbox(t *testing.T) {
+	columns := []string{"seq", "id", "type", "payload", "occurred_at"}
+	eventId := uuid.MustParse("0b8e4f3c-2f4c-4d8e-9d6a-3a1f7f6c1e42")
+	carId := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	occurredAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000,"version":2,"deleted_at":null}`)
+
+	t.Run("publishes and removes events", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT seq, id, type, payload, occurred_at FROM outbox_events ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED")).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, eventId.String(), "CarUpdated", payload, occurredAt))
+		f.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM outbox_events WHERE seq = ANY($1)")).
+			WithArgs(pq.Array([]int64{7})).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
+		repo := New(f.db)
+		published := []entities.Event{}
+
+		// Act
+		n, err := repo.ProcessOutbox(context.Background(), 10, func(events []entities.Event) error {
+			published = append(published, events...)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []entities.Event{{
+			Id:         eventId,
+			Type:       entities.EventCarUpdated,
+			Car:        entities.Car{Id: carId, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 2},
+			OccurredAt: occurredAt,
+		}}, published)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("without events", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(fetchEventsQuery)).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(columns))
+		f.mock.ExpectCommit()
+		repo := New(f.db)
+		called := false
+
+		// Act
+		n, err := repo.ProcessOutbox(context.Background(), 10, func([]entities.Event) error {
+			called = true
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, called)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("keeps events when publishing fails", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		expectedErr := errors.New("broker down")
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(fetchEventsQuery)).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, eventId.String(), "CarUpdated", payload, occurredAt))
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		n, err := repo.ProcessOutbox(context.Background(), 10, func([]entities.Event) error {
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.Equal(t, 0, n)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
@@ -2,54 +2,252 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"gihub.com/gibiw/api-example/internal/audit"
 	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"gihub.com/gibiw/api-example/internal/tracing"
 	"github.com/google/uuid"
+	"github.com/gookit/slog"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	getAllCarsQuery = "SELECT id, brand, model, color, cost FROM cars"
-	getCarQuery     = "SELECT id, brand, model, color, cost FROM cars WHERE id=$1"
-	addCarQuery     = "INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost"
-	deleteCarQuery  = "DELETE FROM cars WHERE id=$1"
-	updateCarQuery  = "UPDATE cars SET brand=$1, model=$2, color=$3, cost=$4 WHERE id=$5"
+	getAllCarsQuery        = "SELECT id, brand, model, color, cost, version, deleted_at FROM cars"
+	getCarQuery            = "SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 AND deleted_at IS NULL"
+	getCarWithDeletedQuery = "SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1"
+	lockCarQuery           = "SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 FOR UPDATE"
+	addCarQuery            = "INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost, version, deleted_at"
+	deleteCarQuery         = "UPDATE cars SET deleted_at=now(), version=version+1 WHERE id=$1 RETURNING id, brand, model, color, cost, version, deleted_at"
+	updateCarQuery         = "UPDATE cars SET brand=$1, model=$2, color=$3, cost=$4, version=version+1 WHERE id=$5 RETURNING id, brand, model, color, cost, version, deleted_at"
+	restoreCarQuery        = "UPDATE cars SET deleted_at=NULL, version=version+1 WHERE id=$1 RETURNING id, brand, model, color, cost, version, deleted_at"
+	purgeCarsQuery         = "WITH purged AS (DELETE FROM cars WHERE deleted_at < $1 RETURNING id, brand, model, color, cost, version, deleted_at) " +
+		"INSERT INTO car_revisions (car_id, revision, operation, old_values, actor, request_id) " +
+		"SELECT id, version+1, 'purge', " + carValuesJSON + ", $2, $3 FROM purged"
+
+	// Imported cars are copied into a temporary table first, so they can be
+	// inserted with their revisions and events in one statement.
+	createImportTableQuery = "CREATE TEMPORARY TABLE cars_import (brand varchar (50), model varchar (50), color varchar (50), cost numeric) ON COMMIT DROP"
+	insertImportedQuery    = "WITH inserted AS (INSERT INTO cars (brand, model, color, cost) SELECT brand, model, color, cost FROM cars_import RETURNING id, brand, model, color, cost, version, deleted_at), " +
+		"revisions AS (INSERT INTO car_revisions (car_id, revision, operation, new_values, actor, request_id) " +
+		"SELECT id, version, 'insert', " + carValuesJSON + ", $1, $2 FROM inserted) " +
+		"INSERT INTO outbox_events (type, car_id, payload) SELECT 'CarCreated', id, " + carPayloadJSON + " FROM inserted"
+
+	declareExportCursorQuery = "DECLARE cars_export NO SCROLL CURSOR FOR "
+	fetchExportCursorQuery   = "FETCH FORWARD 500 FROM cars_export"
+	exportBatchSize          = 500
 )
 
+// CarRepository stores cars in Postgres. Every write of a car is recorded
+// as a revision and announced by an event in the outbox, both in the
+// transaction of the write. See GetCarRevisions and ProcessOutbox.
 type CarRepository struct {
 	db *sqlx.DB
+	// tx runs the statements that need a transaction of their own. They are
+	// not retried, an export has already passed cars on when it fails.
+	tx *TxManager
 }
 
 func New(db *sqlx.DB) *CarRepository {
 	return &CarRepository{
 		db: db,
+		tx: NewTxManager(db, WithMaxAttempts(1)),
 	}
 }
 
-func (r *CarRepository) GetCars(ctx context.Context) ([]entities.Car, error) {
+var sortColumns = map[entities.SortField]string{
+	entities.SortById:    "id",
+	entities.SortByBrand: "brand",
+	entities.SortByModel: "model",
+	entities.SortByColor: "color",
+	entities.SortByCost:  "cost",
+}
+
+func (r *CarRepository) GetCars(ctx context.Context, filter entities.CarFilter) (_ []entities.Car, err error) {
+	query, args, err := buildGetCarsQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := startSpan(ctx, "CarRepository.GetCars", "SELECT", query)
+	defer tracing.End(span, &err)
+	logger.FromContext(ctx).WithFields(slog.M{"query": query, "args": args}).Debug("selecting cars")
+
 	cars := []entities.Car{}
-	if err := r.db.SelectContext(ctx, &cars, getAllCarsQuery); err != nil {
+	if err = conn(ctx, r.db).SelectContext(ctx, &cars, query, args...); err != nil {
 		return nil, err
 	}
 
 	return cars, nil
 }
 
-func (r *CarRepository) GetCarById(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+// ExportCars calls fn for every car matching the filter, in the order of the
+// filter. Cars are fetched from a server-side cursor in batches, so only one
+// batch is held in memory at a time. The export stops at the first error
+// returned by fn.
+func (r *CarRepository) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) (err error) {
+	query, args, err := buildGetCarsQuery(filter)
+	if err != nil {
+		return err
+	}
+	ctx, span := startSpan(ctx, "CarRepository.ExportCars", "SELECT", query)
+	defer tracing.End(span, &err)
+	logger.FromContext(ctx).WithFields(slog.M{"query": query, "args": args}).Debug("exporting cars")
+
+	// A cursor only lives as long as its transaction.
+	return r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		tx, _ := txFromContext(ctx)
+		if _, err := tx.ExecContext(ctx, declareExportCursorQuery+query, args...); err != nil {
+			return err
+		}
+
+		for {
+			n, err := fetchExportBatch(ctx, tx, fn)
+			if err != nil {
+				return err
+			}
+			if n < exportBatchSize {
+				return nil
+			}
+		}
+	})
+}
+
+func fetchExportBatch(ctx context.Context, tx *sqlx.Tx, fn func(entities.Car) error) (int, error) {
+	rows, err := tx.QueryxContext(ctx, fetchExportCursorQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		car := entities.Car{}
+		if err := rows.StructScan(&car); err != nil {
+			return n, err
+		}
+		if err := fn(car); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, rows.Err()
+}
+
+func buildGetCarsQuery(filter entities.CarFilter) (string, []interface{}, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = entities.SortById
+	}
+	column, ok := sortColumns[sortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort field %q", sortBy)
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Order == entities.OrderDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	args := []interface{}{}
+	addCondition := func(format string, values ...interface{}) {
+		placeholders := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			args = append(args, v)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if filter.Brand != "" {
+		addCondition("brand=%s", filter.Brand)
+	}
+	if filter.Model != "" {
+		addCondition("model=%s", filter.Model)
+	}
+	if filter.Color != "" {
+		addCondition("color=%s", filter.Color)
+	}
+	if filter.MinCost != nil {
+		addCondition("cost>=%s", *filter.MinCost)
+	}
+	if filter.MaxCost != nil {
+		addCondition("cost<=%s", *filter.MaxCost)
+	}
+	if filter.After != nil {
+		if sortBy == entities.SortById {
+			addCondition("id"+comparison+"%s", filter.After.Id)
+		} else {
+			addCondition("("+column+", id)"+comparison+"(%s, %s)", filter.After.Value, filter.After.Id)
+		}
+	}
+
+	query := getAllCarsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if sortBy == entities.SortById {
+		query += fmt.Sprintf(" ORDER BY id %s", direction)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return query, args, nil
+}
+
+// GetCarById returns the car with the id. A deleted car is only returned
+// with includeDeleted.
+func (r *CarRepository) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (_ entities.Car, err error) {
+	query := getCarQuery
+	if includeDeleted {
+		query = getCarWithDeletedQuery
+	}
+	ctx, span := startSpan(ctx, "CarRepository.GetCarById", "SELECT", query)
+	defer tracing.End(span, &err)
+
 	car := entities.Car{}
 
-	if err := r.db.GetContext(ctx, &car, getCarQuery, id); err != nil {
+	if err = conn(ctx, r.db).GetContext(ctx, &car, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Car{}, carNotFound(id)
+		}
 		return entities.Car{}, err
 	}
 
 	return car, nil
 }
 
-func (r *CarRepository) AddCar(ctx context.Context, car entities.Car) (entities.Car, error) {
+func (r *CarRepository) AddCar(ctx context.Context, car entities.Car) (_ entities.Car, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.AddCar", "INSERT", addCarQuery)
+	defer tracing.End(span, &err)
+
 	newCar := entities.Car{}
 
-	err := r.db.QueryRowxContext(ctx, addCarQuery, car.Brand, car.Model, car.Color, car.Cost).StructScan(&newCar)
+	err = r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		err := conn(ctx, r.db).QueryRowxContext(ctx, addCarQuery, car.Brand, car.Model, car.Color, car.Cost).StructScan(&newCar)
+		if err != nil {
+			return mapError(ctx, err)
+		}
+		return r.recordChange(ctx, entities.RevisionInsert, nil, &newCar)
+	})
 
 	if err != nil {
 		return entities.Car{}, err
@@ -58,26 +256,261 @@ func (r *CarRepository) AddCar(ctx context.Context, car entities.Car) (entities.
 	return newCar, nil
 }
 
-func (r *CarRepository) DeleteCarById(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, deleteCarQuery, id)
+// DeleteCarById marks the car as deleted only if it still has the given
+// version. The car is kept until it is purged.
+func (r *CarRepository) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) (err error) {
+	ctx, span := startSpan(ctx, "CarRepository.DeleteCarById", "UPDATE", deleteCarQuery)
+	defer tracing.End(span, &err)
+
+	return r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		old, err := r.lockCar(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkWritable(old, version); err != nil {
+			return err
+		}
+
+		car := entities.Car{}
+		if err := conn(ctx, r.db).QueryRowxContext(ctx, deleteCarQuery, id).StructScan(&car); err != nil {
+			return err
+		}
+		return r.recordChange(ctx, entities.RevisionDelete, &old, &car)
+	})
+}
+
+// UpdateCar updates the car only if it still has car.Version and returns it
+// with the incremented version.
+func (r *CarRepository) UpdateCar(ctx context.Context, car entities.Car) (_ entities.Car, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.UpdateCar", "UPDATE", updateCarQuery)
+	defer tracing.End(span, &err)
+
+	newCar := entities.Car{}
+
+	err = r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		old, err := r.lockCar(ctx, car.Id)
+		if err != nil {
+			return err
+		}
+		if err := checkWritable(old, car.Version); err != nil {
+			return err
+		}
+
+		err = conn(ctx, r.db).QueryRowxContext(ctx, updateCarQuery, car.Brand, car.Model, car.Color, car.Cost, car.Id).StructScan(&newCar)
+		if err != nil {
+			return mapError(ctx, err)
+		}
+		return r.recordChange(ctx, entities.RevisionUpdate, &old, &newCar)
+	})
+
 	if err != nil {
-		return err
+		return entities.Car{}, err
 	}
 
-	return nil
+	return newCar, nil
 }
 
-func (r *CarRepository) UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error) {
-	_, err := r.GetCarById(ctx, car.Id)
+// RestoreCar undoes the delete of the car only if it still has the given
+// version and returns it with the incremented version.
+func (r *CarRepository) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (_ entities.Car, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.RestoreCar", "UPDATE", restoreCarQuery)
+	defer tracing.End(span, &err)
+
+	car := entities.Car{}
+
+	err = r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		old, err := r.lockCar(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkRestorable(old, version); err != nil {
+			return err
+		}
+
+		if err := conn(ctx, r.db).QueryRowxContext(ctx, restoreCarQuery, id).StructScan(&car); err != nil {
+			return err
+		}
+		return r.recordChange(ctx, entities.RevisionRestore, &old, &car)
+	})
+
 	if err != nil {
 		return entities.Car{}, err
 	}
 
-	_, err = r.db.ExecContext(ctx, updateCarQuery, car.Brand, car.Model, car.Color, car.Cost, car.Id)
+	return car, nil
+}
+
+// PurgeDeletedCars removes the cars deleted before the given time for good
+// and returns how many were removed. Their history is kept.
+func (r *CarRepository) PurgeDeletedCars(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.PurgeDeletedCars", "DELETE", purgeCarsQuery)
+	defer tracing.End(span, &err)
+	origin := audit.FromContext(ctx)
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, purgeCarsQuery, before, origin.Actor, origin.RequestId)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ImportCars stores the batches of cars in one transaction, each batch with a
+// single COPY. Either all cars are stored or none.
+func (r *CarRepository) ImportCars(ctx context.Context, batches [][]entities.Car) (err error) {
+	copyQuery := pq.CopyIn("cars_import", "brand", "model", "color", "cost")
+	ctx, span := startSpan(ctx, "CarRepository.ImportCars", "COPY", copyQuery)
+	defer tracing.End(span, &err)
+	origin := audit.FromContext(ctx)
+
+	return r.tx.RunInTx(ctx, func(ctx context.Context) error {
+		tx, _ := txFromContext(ctx)
+		if _, err := tx.ExecContext(ctx, createImportTableQuery); err != nil {
+			return err
+		}
+		for _, batch := range batches {
+			if err := copyCars(ctx, tx, copyQuery, batch); err != nil {
+				return mapError(ctx, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, insertImportedQuery, origin.Actor, origin.RequestId); err != nil {
+			return mapError(ctx, err)
+		}
+		return nil
+	})
+}
 
+func copyCars(ctx context.Context, tx *sqlx.Tx, copyQuery string, cars []entities.Car) error {
+	stmt, err := tx.PrepareContext(ctx, copyQuery)
 	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range cars {
+		if _, err := stmt.ExecContext(ctx, c.Brand, c.Model, c.Color, c.Cost); err != nil {
+			return err
+		}
+	}
+
+	// An Exec without arguments ends the COPY and reports its errors.
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// lockCar returns the car and locks it until the end of the transaction,
+// so the checks of a write hold until it is done.
+func (r *CarRepository) lockCar(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+	car := entities.Car{}
+
+	if err := conn(ctx, r.db).GetContext(ctx, &car, lockCarQuery, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Car{}, carNotFound(id)
+		}
 		return entities.Car{}, err
 	}
 
 	return car, nil
 }
+
+// checkWritable tells why a write conditioned on the version may not change
+// the car: it is deleted or has been changed meanwhile.
+func checkWritable(car entities.Car, version int64) error {
+	switch {
+	case car.DeletedAt != nil:
+		return carNotFound(car.Id)
+	case car.Version != version:
+		return carVersionMismatch(car.Id)
+	}
+
+	return nil
+}
+
+// checkRestorable tells why the car may not be restored: it is not deleted
+// or has been changed meanwhile.
+func checkRestorable(car entities.Car, version int64) error {
+	switch {
+	case car.DeletedAt == nil:
+		return carNotDeleted(car.Id)
+	case car.Version != version:
+		return carVersionMismatch(car.Id)
+	}
+
+	return nil
+}
+
+// startSpan starts a client span for a query. Queries only carry
+// placeholders, so the statement is safe to record.
+func startSpan(ctx context.Context, name, operation, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBStatement(query),
+		),
+	)
+}
+
+func carNotFound(id uuid.UUID) error {
+	return fmt.Errorf("car with id %s: %w", id, entities.ErrNotFound)
+}
+
+func carNotDeleted(id uuid.UUID) error {
+	return fmt.Errorf("%w: car with id %s is not deleted", entities.ErrConflict, id)
+}
+
+func carVersionMismatch(id uuid.UUID) error {
+	return fmt.Errorf("car with id %s: %w", id, entities.ErrVersionMismatch)
+}
+
+// constraintMessages describe violated constraints to clients. The driver
+// messages name tables and columns, so they are only logged.
+var constraintMessages = map[string]string{
+	"cars_pkey":             "car already exists",
+	"api_keys_key_hash_key": "api key already exists",
+	"api_keys_role_check":   "role is not supported",
+}
+
+// codeMessages describe the errors of constraints without a message of their own.
+var codeMessages = map[string]string{
+	"unique_violation":             "value already exists",
+	"not_null_violation":           "required value is missing",
+	"check_violation":              "value is not allowed",
+	"foreign_key_violation":        "referenced value does not exist",
+	"string_data_right_truncation": "value is too long",
+	"numeric_value_out_of_range":   "number is out of range",
+	"invalid_text_representation":  "value has an invalid format",
+}
+
+// mapError translates Postgres constraint violations into domain errors.
+func mapError(ctx context.Context, err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	class := pqErr.Code.Class()
+	if class != "23" && class != "22" {
+		return err
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"code":       string(pqErr.Code),
+		"constraint": pqErr.Constraint,
+		"error":      pqErr.Message,
+	}).Info("database rejected the statement")
+
+	msg, ok := constraintMessages[pqErr.Constraint]
+	if !ok {
+		msg, ok = codeMessages[pqErr.Code.Name()]
+	}
+	if !ok {
+		msg = "value is invalid"
+	}
+
+	if pqErr.Code.Name() == "unique_violation" {
+		return fmt.Errorf("%w: %s", entities.ErrConflict, msg)
+	}
+	return fmt.Errorf("%w: %s", entities.ErrValidation, msg)
+}
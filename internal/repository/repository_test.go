@@ -2,16 +2,22 @@ package repository
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
+	"gihub.com/gibiw/api-example/internal/audit"
 	"gihub.com/gibiw/api-example/internal/entities"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
 func TestCarRepository_GetCars(t *testing.T) {
@@ -22,30 +28,32 @@ func TestCarRepository_GetCars(t *testing.T) {
 
 		expectedCars := []entities.Car{
 			{
-				Id:    uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
-				Brand: "Audi",
-				Model: "A3",
-				Color: "Red",
-				Cost:  10000,
+				Id:      uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
+				Brand:   "Audi",
+				Model:   "A3",
+				Color:   "Red",
+				Cost:    10000,
+				Version: 1,
 			},
 			{
-				Id:    uuid.MustParse("3d997272-468f-4b66-91db-00c39f0ef717"),
-				Brand: "BMW",
-				Model: "X6",
-				Color: "Black",
-				Cost:  20000,
+				Id:      uuid.MustParse("3d997272-468f-4b66-91db-00c39f0ef717"),
+				Brand:   "BMW",
+				Model:   "X6",
+				Color:   "Black",
+				Cost:    20000,
+				Version: 1,
 			},
 		}
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"}).
-			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000).
-			AddRow("3d997272-468f-4b66-91db-00c39f0ef717", "BMW", "X6", "Black", 20000)
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"}).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1).
+			AddRow("3d997272-468f-4b66-91db-00c39f0ef717", "BMW", "X6", "Black", 20000, 1)
 
-		f.mock.ExpectQuery("SELECT id, brand, model, color, cost FROM cars").
+		f.mock.ExpectQuery("SELECT id, brand, model, color, cost, version, deleted_at FROM cars").
 			WillReturnRows(rows)
 		repo := New(f.db)
 
 		// Act
-		cars, err := repo.GetCars(context.Background())
+		cars, err := repo.GetCars(context.Background(), entities.CarFilter{})
 
 		// Assert
 		assert.NoError(t, err)
@@ -57,20 +65,101 @@ func TestCarRepository_GetCars(t *testing.T) {
 		f := NewFixture(t)
 		defer f.Teardown()
 
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"})
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"})
 
-		f.mock.ExpectQuery("SELECT id, brand, model, color, cost FROM cars").
+		f.mock.ExpectQuery("SELECT id, brand, model, color, cost, version, deleted_at FROM cars").
 			WillReturnRows(rows)
 		repo := New(f.db)
 
 		// Act
-		cars, err := repo.GetCars(context.Background())
+		cars, err := repo.GetCars(context.Background(), entities.CarFilter{})
 
 		// Assert
 		assert.NoError(t, err)
 		assert.ElementsMatch(t, []entities.Car{}, cars)
 	})
 
+	t.Run("with filter and cursor", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		minCost := uint64(5000)
+		after := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+		filter := entities.CarFilter{
+			Brand:   "BMW",
+			MinCost: &minCost,
+			SortBy:  entities.SortByCost,
+			Order:   entities.OrderDesc,
+			Limit:   11,
+			After:   &entities.Cursor{Value: "30000", Id: after},
+		}
+		expectedCars := []entities.Car{
+			{
+				Id:      uuid.MustParse("3d997272-468f-4b66-91db-00c39f0ef717"),
+				Brand:   "BMW",
+				Model:   "X6",
+				Color:   "Black",
+				Cost:    20000,
+				Version: 1,
+			},
+		}
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"}).
+			AddRow("3d997272-468f-4b66-91db-00c39f0ef717", "BMW", "X6", "Black", 20000, 1)
+
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE deleted_at IS NULL AND brand=$1 AND cost>=$2 AND (cost, id)<($3, $4) ORDER BY cost DESC, id DESC LIMIT $5")).
+			WithArgs("BMW", minCost, "30000", after, uint64(11)).
+			WillReturnRows(rows)
+		repo := New(f.db)
+
+		// Act
+		cars, err := repo.GetCars(context.Background(), filter)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expectedCars, cars)
+	})
+
+	t.Run("with cursor sorted by id", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		after := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+		filter := entities.CarFilter{
+			SortBy: entities.SortById,
+			Order:  entities.OrderAsc,
+			After:  &entities.Cursor{Value: after.String(), Id: after},
+		}
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"})
+
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE deleted_at IS NULL AND id>$1 ORDER BY id ASC")).
+			WithArgs(after).
+			WillReturnRows(rows)
+		repo := New(f.db)
+
+		// Act
+		cars, err := repo.GetCars(context.Background(), filter)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, cars)
+	})
+
+	t.Run("with unknown sort field", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		repo := New(f.db)
+
+		// Act
+		cars, err := repo.GetCars(context.Background(), entities.CarFilter{SortBy: "price"})
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, cars)
+	})
+
 	t.Run("with error", func(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
@@ -78,12 +167,12 @@ func TestCarRepository_GetCars(t *testing.T) {
 
 		expectErr := errors.New("test error")
 
-		f.mock.ExpectQuery("SELECT id, brand, model, color, cost FROM cars").
+		f.mock.ExpectQuery("SELECT id, brand, model, color, cost, version, deleted_at FROM cars").
 			WillReturnError(expectErr)
 		repo := New(f.db)
 
 		// Act
-		cars, err := repo.GetCars(context.Background())
+		cars, err := repo.GetCars(context.Background(), entities.CarFilter{})
 
 		// Assert
 		assert.Error(t, expectErr, err)
@@ -91,6 +180,70 @@ func TestCarRepository_GetCars(t *testing.T) {
 	})
 }
 
+func TestCarRepository_ExportCars(t *testing.T) {
+	carColumns := []string{"id", "brand", "model", "color", "cost", "version"}
+
+	t.Run("with cars", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		rows := sqlmock.NewRows(carColumns).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1).
+			AddRow("5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f", "Audi", "A4", "Blue", 20000, 3)
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectExec(regexp.QuoteMeta("DECLARE cars_export NO SCROLL CURSOR FOR SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE deleted_at IS NULL AND brand=$1 ORDER BY cost DESC, id DESC")).
+			WithArgs("Audi").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectQuery(regexp.QuoteMeta(fetchExportCursorQuery)).
+			WillReturnRows(rows)
+		f.mock.ExpectCommit()
+		repo := New(f.db)
+		exported := []string{}
+
+		// Act
+		err := repo.ExportCars(context.Background(), entities.CarFilter{
+			Brand:  "Audi",
+			SortBy: entities.SortByCost,
+			Order:  entities.OrderDesc,
+		}, func(c entities.Car) error {
+			exported = append(exported, c.Model)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"A3", "A4"}, exported)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with callback error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		expectedErr := errors.New("client gone")
+		rows := sqlmock.NewRows(carColumns).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1)
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectExec(regexp.QuoteMeta(declareExportCursorQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.mock.ExpectQuery(regexp.QuoteMeta(fetchExportCursorQuery)).
+			WillReturnRows(rows)
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		err := repo.ExportCars(context.Background(), entities.CarFilter{}, func(entities.Car) error {
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
 func TestCarRepository_GetCarById(t *testing.T) {
 	t.Run("with car", func(t *testing.T) {
 		// Arrange
@@ -98,22 +251,23 @@ func TestCarRepository_GetCarById(t *testing.T) {
 		defer f.Teardown()
 		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
 		expectedCar := entities.Car{
-			Id:    id,
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
+			Id:      id,
+			Brand:   "Audi",
+			Model:   "A3",
+			Color:   "Red",
+			Cost:    10000,
+			Version: 1,
 		}
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"}).
-			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000)
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"}).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1)
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 AND deleted_at IS NULL")).
 			WithArgs(id).
 			WillReturnRows(rows)
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.GetCarById(context.Background(), id)
+		car, err := repo.GetCarById(context.Background(), id, false)
 
 		// Assert
 		assert.NoError(t, err)
@@ -126,18 +280,18 @@ func TestCarRepository_GetCarById(t *testing.T) {
 		defer f.Teardown()
 		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
 
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"})
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"})
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 AND deleted_at IS NULL")).
 			WithArgs(id).
 			WillReturnRows(rows)
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.GetCarById(context.Background(), id)
+		car, err := repo.GetCarById(context.Background(), id, false)
 
 		// Assert
-		assert.Error(t, sql.ErrNoRows, err)
+		assert.ErrorIs(t, err, entities.ErrNotFound)
 		assert.Equal(t, entities.Car{}, car)
 	})
 
@@ -149,18 +303,42 @@ func TestCarRepository_GetCarById(t *testing.T) {
 		expectErr := errors.New("test error")
 		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 AND deleted_at IS NULL")).
 			WithArgs(id).
 			WillReturnError(expectErr)
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.GetCarById(context.Background(), id)
+		car, err := repo.GetCarById(context.Background(), id, false)
 
 		// Assert
 		assert.Error(t, expectErr, err)
 		assert.Equal(t, entities.Car{}, car)
 	})
+
+	t.Run("records span", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 AND deleted_at IS NULL")).
+			WithArgs(id).
+			WillReturnError(errors.New("test error"))
+		repo := New(f.db)
+
+		// Act
+		_, _ = repo.GetCarById(context.Background(), id, false)
+
+		// Assert
+		spans := recorder.Ended()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "CarRepository.GetCarById", spans[0].Name())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Contains(t, spans[0].Attributes(), semconv.DBStatement(getCarQuery))
+	})
 }
 
 func TestCarRepository_AddCar(t *testing.T) {
@@ -169,27 +347,38 @@ func TestCarRepository_AddCar(t *testing.T) {
 		f := NewFixture(t)
 		defer f.Teardown()
 		expectedCar := entities.Car{
-			Id:    uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
+			Id:      uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
+			Brand:   "Audi",
+			Model:   "A3",
+			Color:   "Red",
+			Cost:    10000,
+			Version: 1,
 		}
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"}).
-			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000)
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"}).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1)
+		ctx := audit.WithOrigin(context.Background(), audit.Origin{Actor: "key-1", RequestId: "req-1"})
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost")).
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost, version, deleted_at")).
 			WithArgs(expectedCar.Brand, expectedCar.Model, expectedCar.Color, expectedCar.Cost).
 			WillReturnRows(rows)
+		f.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO car_revisions (car_id, revision, operation, old_values, new_values, actor, request_id) VALUES ($1, $2, $3, $4, $5, $6, $7)")).
+			WithArgs(expectedCar.Id, int64(1), "insert", nil, `{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`, "key-1", "req-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox_events (type, car_id, payload) VALUES ($1, $2, $3)")).
+			WithArgs("CarCreated", expectedCar.Id, `{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000,"version":1,"deleted_at":null}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
 
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.AddCar(context.Background(), expectedCar)
+		car, err := repo.AddCar(ctx, expectedCar)
 
 		// Assert
 		assert.NoError(t, err)
 		assert.Equal(t, expectedCar, car)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 
 	t.Run("with error", func(t *testing.T) {
@@ -199,45 +388,275 @@ func TestCarRepository_AddCar(t *testing.T) {
 
 		expectErr := errors.New("test error")
 		expectedCar := entities.Car{
-			Id:    uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
+			Id:      uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
+			Brand:   "Audi",
+			Model:   "A3",
+			Color:   "Red",
+			Cost:    10000,
+			Version: 1,
 		}
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost")).
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost, version, deleted_at")).
 			WithArgs(expectedCar.Brand, expectedCar.Model, expectedCar.Color, expectedCar.Cost).
 			WillReturnError(expectErr)
+		f.mock.ExpectRollback()
 		repo := New(f.db)
 
 		// Act
 		car, err := repo.AddCar(context.Background(), expectedCar)
 
 		// Assert
-		assert.Error(t, expectErr, err)
+		assert.ErrorIs(t, err, expectErr)
 		assert.Equal(t, entities.Car{}, car)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with revision error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost", "version"}).
+			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000, 1)
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(addCarQuery)).
+			WillReturnRows(rows)
+		f.mock.ExpectExec(regexp.QuoteMeta(addRevisionQuery)).
+			WillReturnError(expectErr)
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		car, err := repo.AddCar(context.Background(), entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000})
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, entities.Car{}, car)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with unique violation", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		car := entities.Car{
+			Brand:   "Audi",
+			Model:   "A3",
+			Color:   "Red",
+			Cost:    10000,
+			Version: 1,
+		}
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost, version, deleted_at")).
+			WithArgs(car.Brand, car.Model, car.Color, car.Cost).
+			WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.AddCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrConflict)
+	})
+
+	t.Run("with too long value", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		car := entities.Car{
+			Brand:   "Audi",
+			Model:   "A3",
+			Color:   "Red",
+			Cost:    10000,
+			Version: 1,
+		}
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO cars (brand, model, color, cost) VALUES ($1, $2, $3, $4) RETURNING id, brand, model, color, cost, version, deleted_at")).
+			WithArgs(car.Brand, car.Model, car.Color, car.Cost).
+			WillReturnError(&pq.Error{Code: "22001", Message: "value too long for type character varying(50)"})
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.AddCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrValidation)
+	})
+}
+
+func TestCarRepository_ImportCars(t *testing.T) {
+	copyQuery := `COPY "cars_import" ("brand", "model", "color", "cost") FROM STDIN`
+	batches := [][]entities.Car{
+		{{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}, {Brand: "Audi", Model: "A4", Color: "Blue", Cost: 20000}},
+		{{Brand: "Ford", Model: "Focus", Color: "Green", Cost: 8000}},
+	}
+
+	t.Run("with valid cars", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		ctx := audit.WithOrigin(context.Background(), audit.Origin{Actor: "key-1", RequestId: "req-1"})
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectExec(regexp.QuoteMeta("CREATE TEMPORARY TABLE cars_import (brand varchar (50), model varchar (50), color varchar (50), cost numeric) ON COMMIT DROP")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, batch := range batches {
+			prep := f.mock.ExpectPrepare(regexp.QuoteMeta(copyQuery))
+			for _, c := range batch {
+				prep.ExpectExec().WithArgs(c.Brand, c.Model, c.Color, c.Cost).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			prep.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		f.mock.ExpectExec(regexp.QuoteMeta("WITH inserted AS (INSERT INTO cars (brand, model, color, cost) SELECT brand, model, color, cost FROM cars_import RETURNING id, brand, model, color, cost, version, deleted_at), "+
+			"revisions AS (INSERT INTO car_revisions (car_id, revision, operation, new_values, actor, request_id) "+
+			"SELECT id, version, 'insert', jsonb_build_object('brand', brand, 'model', model, 'color', color, 'cost', cost, 'deleted_at', deleted_at), $1, $2 FROM inserted) "+
+			"INSERT INTO outbox_events (type, car_id, payload) "+
+			"SELECT 'CarCreated', id, jsonb_build_object('id', id, 'brand', brand, 'model', model, 'color', color, 'cost', cost, 'version', version, 'deleted_at', deleted_at) FROM inserted")).
+			WithArgs("key-1", "req-1").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		f.mock.ExpectCommit()
+		repo := New(f.db)
+
+		// Act
+		err := repo.ImportCars(ctx, batches)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with rejected batch", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectExec(regexp.QuoteMeta(createImportTableQuery)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		prep := f.mock.ExpectPrepare(regexp.QuoteMeta(copyQuery))
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		prep.ExpectExec().WithArgs().WillReturnError(&pq.Error{Code: "22001", Message: "value too long for type character varying(50)"})
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		err := repo.ImportCars(context.Background(), batches)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrValidation)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 }
 
 func TestCarRepository_DeleteCarById(t *testing.T) {
+	columns := []string{"id", "brand", "model", "color", "cost", "version", "deleted_at"}
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
 		defer f.Teardown()
-		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
 
-		f.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cars WHERE id=$1")).
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost, version, deleted_at FROM cars WHERE id=$1 FOR UPDATE")).
 			WithArgs(id).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 1, nil))
+		f.mock.ExpectQuery(regexp.QuoteMeta("UPDATE cars SET deleted_at=now(), version=version+1 WHERE id=$1 RETURNING id, brand, model, color, cost, version, deleted_at")).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, deletedAt))
+		f.mock.ExpectExec(regexp.QuoteMeta(addRevisionQuery)).
+			WithArgs(id, int64(2), "delete",
+				`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`,
+				`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":"2026-10-01T12:00:00Z"}`,
+				"", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectExec(regexp.QuoteMeta(addEventQuery)).
+			WithArgs("CarDeleted", id, `{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000,"version":2,"deleted_at":"2026-10-01T12:00:00Z"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
 
 		repo := New(f.db)
 
 		// Act
-		err := repo.DeleteCarById(context.Background(), id)
+		err := repo.DeleteCarById(context.Background(), id, 1)
 
 		// Assert
 		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("without car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns))
+		f.mock.ExpectRollback()
+
+		repo := New(f.db)
+
+		// Act
+		err := repo.DeleteCarById(context.Background(), id, 1)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with deleted car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, deletedAt))
+		f.mock.ExpectRollback()
+
+		repo := New(f.db)
+
+		// Act
+		err := repo.DeleteCarById(context.Background(), id, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with stale version", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, nil))
+		f.mock.ExpectRollback()
+
+		repo := New(f.db)
+
+		// Act
+		err := repo.DeleteCarById(context.Background(), id, 1)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrVersionMismatch)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 
 	t.Run("with error", func(t *testing.T) {
@@ -246,80 +665,115 @@ func TestCarRepository_DeleteCarById(t *testing.T) {
 		defer f.Teardown()
 
 		expectErr := errors.New("test error")
-		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
 
-		f.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cars WHERE id=$1")).
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 1, nil))
+		f.mock.ExpectQuery(regexp.QuoteMeta(deleteCarQuery)).
 			WithArgs(id).
 			WillReturnError(expectErr)
+		f.mock.ExpectRollback()
 
 		repo := New(f.db)
 
 		// Act
-		err := repo.DeleteCarById(context.Background(), id)
+		err := repo.DeleteCarById(context.Background(), id, 1)
 
 		// Assert
-		assert.ErrorIs(t, expectErr, err)
+		assert.ErrorIs(t, err, expectErr)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 }
 
 func TestCarRepository_UpdateCar(t *testing.T) {
+	columns := []string{"id", "brand", "model", "color", "cost", "version", "deleted_at"}
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	car := entities.Car{
+		Id:      id,
+		Brand:   "Audi",
+		Model:   "A3",
+		Color:   "Red",
+		Cost:    10000,
+		Version: 1,
+	}
+
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
 		defer f.Teardown()
-		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
-		expectedCar := entities.Car{
-			Id:    id,
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
-		}
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"}).
-			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000)
+		expectedCar := car
+		expectedCar.Version = 2
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
 			WithArgs(id).
-			WillReturnRows(rows)
-
-		f.mock.ExpectExec(regexp.QuoteMeta("UPDATE cars SET brand=$1, model=$2, color=$3, cost=$4 WHERE id=$5")).
-			WithArgs(expectedCar.Brand, expectedCar.Model, expectedCar.Color, expectedCar.Cost, expectedCar.Id).
-			WillReturnResult(sqlmock.NewResult(1, 1))
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Green", 9000, 1, nil))
+		f.mock.ExpectQuery(regexp.QuoteMeta("UPDATE cars SET brand=$1, model=$2, color=$3, cost=$4, version=version+1 WHERE id=$5 RETURNING id, brand, model, color, cost, version, deleted_at")).
+			WithArgs(car.Brand, car.Model, car.Color, car.Cost, car.Id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, nil))
+		f.mock.ExpectExec(regexp.QuoteMeta(addRevisionQuery)).
+			WithArgs(id, int64(2), "update",
+				`{"brand":"Audi","model":"A3","color":"Green","cost":9000,"deleted_at":null}`,
+				`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`,
+				"", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectExec(regexp.QuoteMeta(addEventQuery)).
+			WithArgs("CarUpdated", id, `{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000,"version":2,"deleted_at":null}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
 
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.UpdateCar(context.Background(), expectedCar)
+		updated, err := repo.UpdateCar(context.Background(), car)
 
 		// Assert
 		assert.NoError(t, err)
-		assert.Equal(t, expectedCar, car)
+		assert.Equal(t, expectedCar, updated)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 
 	t.Run("without car", func(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
 		defer f.Teardown()
-		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
-		expectedCar := entities.Car{
-			Id:    id,
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
-		}
 
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"})
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns))
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		updated, err := repo.UpdateCar(context.Background(), car)
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).WithArgs(id).WillReturnRows(rows)
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		assert.Equal(t, entities.Car{}, updated)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with stale version", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Green", 10000, 2, nil))
+		f.mock.ExpectRollback()
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.UpdateCar(context.Background(), expectedCar)
+		updated, err := repo.UpdateCar(context.Background(), car)
 
 		// Assert
-		assert.Error(t, sql.ErrNoRows, err)
-		assert.Equal(t, entities.Car{}, car)
+		assert.ErrorIs(t, err, entities.ErrVersionMismatch)
+		assert.Equal(t, entities.Car{}, updated)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
 
 	t.Run("with error", func(t *testing.T) {
@@ -328,32 +782,197 @@ func TestCarRepository_UpdateCar(t *testing.T) {
 		defer f.Teardown()
 
 		expectErr := errors.New("test error")
-		id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
-		expectedCar := entities.Car{
-			Id:    id,
-			Brand: "Audi",
-			Model: "A3",
-			Color: "Red",
-			Cost:  10000,
-		}
-		rows := sqlmock.NewRows([]string{"id", "brand", "model", "color", "cost"}).
-			AddRow("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c", "Audi", "A3", "Red", 10000)
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 1, nil))
+		f.mock.ExpectQuery(regexp.QuoteMeta(updateCarQuery)).
+			WithArgs(car.Brand, car.Model, car.Color, car.Cost, car.Id).
+			WillReturnError(expectErr)
+		f.mock.ExpectRollback()
+
+		repo := New(f.db)
 
-		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT id, brand, model, color, cost FROM cars WHERE id=$1")).
+		// Act
+		updated, err := repo.UpdateCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, entities.Car{}, updated)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with too long value", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
 			WithArgs(id).
-			WillReturnRows(rows)
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 1, nil))
+		f.mock.ExpectQuery(regexp.QuoteMeta(updateCarQuery)).
+			WithArgs(car.Brand, car.Model, car.Color, car.Cost, car.Id).
+			WillReturnError(&pq.Error{Code: "22001", Message: "value too long for type character varying(50)"})
+		f.mock.ExpectRollback()
 
-		f.mock.ExpectExec(regexp.QuoteMeta("UPDATE cars SET brand=$1, model=$2, color=$3, cost=$4 WHERE id=$5")).
-			WithArgs(expectedCar.Brand, expectedCar.Model, expectedCar.Color, expectedCar.Cost, expectedCar.Id).
-			WillReturnError(expectErr)
+		repo := New(f.db)
+
+		// Act
+		updated, err := repo.UpdateCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrValidation)
+		assert.Equal(t, entities.Car{}, updated)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestCarRepository_RestoreCar(t *testing.T) {
+	columns := []string{"id", "brand", "model", "color", "cost", "version", "deleted_at"}
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
 
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, deletedAt))
+		f.mock.ExpectQuery(regexp.QuoteMeta("UPDATE cars SET deleted_at=NULL, version=version+1 WHERE id=$1 RETURNING id, brand, model, color, cost, version, deleted_at")).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 3, nil))
+		f.mock.ExpectExec(regexp.QuoteMeta(addRevisionQuery)).
+			WithArgs(id, int64(3), "restore",
+				`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":"2026-10-01T12:00:00Z"}`,
+				`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`,
+				"", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectExec(regexp.QuoteMeta(addEventQuery)).
+			WithArgs("CarRestored", id, `{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000,"version":3,"deleted_at":null}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
 		repo := New(f.db)
 
 		// Act
-		car, err := repo.UpdateCar(context.Background(), expectedCar)
+		car, err := repo.RestoreCar(context.Background(), id, 2)
 
 		// Assert
-		assert.ErrorIs(t, expectErr, err)
-		assert.Equal(t, entities.Car{}, car)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 3}, car)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
 	})
+
+	t.Run("car is not deleted", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 2, nil))
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.RestoreCar(context.Background(), id, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrConflict)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("with stale version", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(regexp.QuoteMeta(lockCarQuery)).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(id.String(), "Audi", "A3", "Red", 10000, 3, deletedAt))
+		f.mock.ExpectRollback()
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.RestoreCar(context.Background(), id, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrVersionMismatch)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
+
+func TestCarRepository_PurgeDeletedCars(t *testing.T) {
+	// Arrange
+	f := NewFixture(t)
+	defer f.Teardown()
+	before := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f.mock.ExpectExec(regexp.QuoteMeta("WITH purged AS (DELETE FROM cars WHERE deleted_at < $1 RETURNING id, brand, model, color, cost, version, deleted_at) "+
+		"INSERT INTO car_revisions (car_id, revision, operation, old_values, actor, request_id) "+
+		"SELECT id, version+1, 'purge', jsonb_build_object('brand', brand, 'model', model, 'color', color, 'cost', cost, 'deleted_at', deleted_at), $2, $3 FROM purged")).
+		WithArgs(before, "", "").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	repo := New(f.db)
+
+	// Act
+	n, err := repo.PurgeDeletedCars(context.Background(), before)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+		msg  string
+	}{
+		{
+			name: "known constraint",
+			err:  &pq.Error{Code: "23505", Constraint: "api_keys_key_hash_key", Message: `duplicate key value violates unique constraint "api_keys_key_hash_key"`},
+			want: entities.ErrConflict,
+			msg:  "conflict: api key already exists",
+		},
+		{
+			name: "too long value",
+			err:  &pq.Error{Code: "22001", Message: "value too long for type character varying(50)"},
+			want: entities.ErrValidation,
+			msg:  "validation failed: value is too long",
+		},
+		{
+			name: "unknown constraint",
+			err:  &pq.Error{Code: "23514", Constraint: "cars_cost_check", Message: `new row for relation "cars" violates check constraint "cars_cost_check"`},
+			want: entities.ErrValidation,
+			msg:  "validation failed: value is not allowed",
+		},
+		{
+			name: "other database error",
+			err:  &pq.Error{Code: "57014", Message: "canceling statement due to user request"},
+			msg:  "pq: canceling statement due to user request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := mapError(context.Background(), tt.err)
+
+			// Assert
+			if tt.want == nil {
+				assert.Equal(t, tt.err, err)
+			} else {
+				assert.ErrorIs(t, err, tt.want)
+			}
+			assert.Equal(t, tt.msg, err.Error())
+		})
+	}
 }
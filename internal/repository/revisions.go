@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/audit"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	addRevisionQuery  = "INSERT INTO car_revisions (car_id, revision, operation, old_values, new_values, actor, request_id) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	getRevisionsQuery = "SELECT car_id, revision, operation, old_values, new_values, actor, request_id, changed_at FROM car_revisions WHERE car_id=$1 ORDER BY revision"
+	getRevisionQuery  = "SELECT car_id, revision, operation, old_values, new_values, actor, request_id, changed_at FROM car_revisions WHERE car_id=$1 AND revision=$2"
+
+	// carValuesJSON builds the entities.CarValues of a row in SQL, for the
+	// statements that record the revisions of many cars at once.
+	carValuesJSON = "jsonb_build_object('brand', brand, 'model', model, 'color', color, 'cost', cost, 'deleted_at', deleted_at)"
+)
+
+type revisionRow struct {
+	CarId     uuid.UUID `db:"car_id"`
+	Revision  int64     `db:"revision"`
+	Operation string    `db:"operation"`
+	OldValues []byte    `db:"old_values"`
+	NewValues []byte    `db:"new_values"`
+	Actor     string    `db:"actor"`
+	RequestId string    `db:"request_id"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+func (row revisionRow) toRevision() (entities.CarRevision, error) {
+	rev := entities.CarRevision{
+		CarId:     row.CarId,
+		Revision:  row.Revision,
+		Operation: entities.RevisionOperation(row.Operation),
+		Actor:     row.Actor,
+		RequestId: row.RequestId,
+		ChangedAt: row.ChangedAt,
+	}
+
+	var err error
+	if rev.Old, err = decodeValues(row.OldValues); err != nil {
+		return entities.CarRevision{}, err
+	}
+	if rev.New, err = decodeValues(row.NewValues); err != nil {
+		return entities.CarRevision{}, err
+	}
+
+	return rev, nil
+}
+
+func decodeValues(data []byte) (*entities.CarValues, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	v := &entities.CarValues{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("decode car values: %w", err)
+	}
+
+	return v, nil
+}
+
+func encodeValues(car *entities.Car) (interface{}, error) {
+	if car == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(car.Values())
+	if err != nil {
+		return nil, err
+	}
+
+	// A string is sent as text, which Postgres takes for jsonb, unlike the
+	// bytea a []byte is sent as.
+	return string(data), nil
+}
+
+// addRevision records the change of a car from old to new.
+func (r *CarRepository) addRevision(ctx context.Context, op entities.RevisionOperation, old, new *entities.Car) error {
+	car := new
+	if car == nil {
+		car = old
+	}
+	oldValues, err := encodeValues(old)
+	if err != nil {
+		return err
+	}
+	newValues, err := encodeValues(new)
+	if err != nil {
+		return err
+	}
+	origin := audit.FromContext(ctx)
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, addRevisionQuery,
+		car.Id, car.Version, string(op), oldValues, newValues, origin.Actor, origin.RequestId)
+
+	return err
+}
+
+// GetCarRevisions returns the history of the car, oldest change first. It is
+// kept after the car is purged.
+func (r *CarRepository) GetCarRevisions(ctx context.Context, id uuid.UUID) (_ []entities.CarRevision, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.GetCarRevisions", "SELECT", getRevisionsQuery)
+	defer tracing.End(span, &err)
+
+	rows := []revisionRow{}
+	if err = conn(ctx, r.db).SelectContext(ctx, &rows, getRevisionsQuery, id); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]entities.CarRevision, 0, len(rows))
+	for _, row := range rows {
+		rev, err := row.toRevision()
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+func (r *CarRepository) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (_ entities.CarRevision, err error) {
+	ctx, span := startSpan(ctx, "CarRepository.GetCarRevision", "SELECT", getRevisionQuery)
+	defer tracing.End(span, &err)
+
+	row := revisionRow{}
+	if err = conn(ctx, r.db).GetContext(ctx, &row, getRevisionQuery, id, revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.CarRevision{}, revisionNotFound(id, revision)
+		}
+		return entities.CarRevision{}, err
+	}
+
+	return row.toRevision()
+}
+
+func revisionNotFound(id uuid.UUID, revision int64) error {
+	return fmt.Errorf("revision %d of car with id %s: %w", revision, id, entities.ErrNotFound)
+}
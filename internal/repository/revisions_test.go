@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarRepository_GetCarRevisions(t *testing.T) {
+	columns := []string{"car_id", "revision", "operation", "old_values", "new_values", "actor", "request_id", "changed_at"}
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	changedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("with revisions", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		rows := sqlmock.NewRows(columns).
+			AddRow(id.String(), 1, "insert", nil, []byte(`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`), "key-1", "req-1", changedAt).
+			AddRow(id.String(), 2, "update", []byte(`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":null}`), []byte(`{"brand":"Audi","model":"A3","color":"Red","cost":9500,"deleted_at":null}`), "key-2", "req-2", changedAt)
+
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT car_id, revision, operation, old_values, new_values, actor, request_id, changed_at FROM car_revisions WHERE car_id=$1 ORDER BY revision")).
+			WithArgs(id).
+			WillReturnRows(rows)
+		repo := New(f.db)
+
+		// Act
+		revisions, err := repo.GetCarRevisions(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.CarRevision{
+			{
+				CarId: id, Revision: 1, Operation: entities.RevisionInsert,
+				New:   &entities.CarValues{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000},
+				Actor: "key-1", RequestId: "req-1", ChangedAt: changedAt,
+			},
+			{
+				CarId: id, Revision: 2, Operation: entities.RevisionUpdate,
+				Old:   &entities.CarValues{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000},
+				New:   &entities.CarValues{Brand: "Audi", Model: "A3", Color: "Red", Cost: 9500},
+				Actor: "key-2", RequestId: "req-2", ChangedAt: changedAt,
+			},
+		}, revisions)
+	})
+
+	t.Run("with broken values", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		rows := sqlmock.NewRows(columns).
+			AddRow(id.String(), 1, "insert", nil, []byte(`{`), "", "", changedAt)
+
+		f.mock.ExpectQuery(regexp.QuoteMeta(getRevisionsQuery)).
+			WithArgs(id).
+			WillReturnRows(rows)
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.GetCarRevisions(context.Background(), id)
+
+		// Assert
+		assert.ErrorContains(t, err, "decode car values")
+	})
+}
+
+func TestCarRepository_GetCarRevision(t *testing.T) {
+	columns := []string{"car_id", "revision", "operation", "old_values", "new_values", "actor", "request_id", "changed_at"}
+	id := uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c")
+	changedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("with revision", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		rows := sqlmock.NewRows(columns).
+			AddRow(id.String(), 3, "purge", []byte(`{"brand":"Audi","model":"A3","color":"Red","cost":10000,"deleted_at":"2026-09-01T12:00:00Z"}`), nil, "", "", changedAt)
+
+		f.mock.ExpectQuery(regexp.QuoteMeta("SELECT car_id, revision, operation, old_values, new_values, actor, request_id, changed_at FROM car_revisions WHERE car_id=$1 AND revision=$2")).
+			WithArgs(id, int64(3)).
+			WillReturnRows(rows)
+		repo := New(f.db)
+
+		// Act
+		rev, err := repo.GetCarRevision(context.Background(), id, 3)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.RevisionPurge, rev.Operation)
+		assert.Nil(t, rev.New)
+		assert.Equal(t, time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC), rev.Old.DeletedAt.UTC())
+	})
+
+	t.Run("without revision", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectQuery(regexp.QuoteMeta(getRevisionQuery)).
+			WithArgs(id, int64(3)).
+			WillReturnRows(sqlmock.NewRows(columns))
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.GetCarRevision(context.Background(), id, 3)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		expectErr := errors.New("test error")
+
+		f.mock.ExpectQuery(regexp.QuoteMeta(getRevisionQuery)).
+			WithArgs(id, int64(3)).
+			WillReturnError(expectErr)
+		repo := New(f.db)
+
+		// Act
+		_, err := repo.GetCarRevision(context.Background(), id, 3)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
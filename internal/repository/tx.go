@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/gookit/slog"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultTxMaxAttempts = 3
+	defaultTxBackoff     = 50 * time.Millisecond
+)
+
+type txKey struct{}
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx, so queries run the
+// same way in and outside of a transaction.
+type queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// TxManager runs units of work in a transaction. The transaction is carried
+// in the context, so the methods of every repository called with that
+// context run in it.
+type TxManager struct {
+	db          *sqlx.DB
+	maxAttempts int
+	backoff     time.Duration
+}
+
+type TxOption func(m *TxManager)
+
+// WithMaxAttempts sets how often a transaction is run before a
+// serialization failure or deadlock is returned. 1 disables retries.
+func WithMaxAttempts(n int) TxOption {
+	return func(m *TxManager) {
+		if n > 0 {
+			m.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff sets the wait before the first retry. It doubles with every
+// further retry.
+func WithBackoff(d time.Duration) TxOption {
+	return func(m *TxManager) {
+		m.backoff = d
+	}
+}
+
+func NewTxManager(db *sqlx.DB, opts ...TxOption) *TxManager {
+	m := &TxManager{
+		db:          db,
+		maxAttempts: defaultTxMaxAttempts,
+		backoff:     defaultTxBackoff,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// RunInTx runs fn in a transaction. It is committed if fn returns nil and
+// rolled back otherwise. A call within a transaction joins it instead of
+// starting another one. Transactions that fail on a serialization failure
+// or a deadlock are run again, so fn must not have effects outside of the
+// transaction.
+func (m *TxManager) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	backoff := m.backoff
+	for attempt := 1; ; attempt++ {
+		err := m.runOnce(ctx, fn)
+		if err == nil || attempt >= m.maxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		logger.FromContext(ctx).WithFields(slog.M{
+			"attempt": attempt,
+			"error":   err.Error(),
+		}).Warn("transaction failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *TxManager) runOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isRetryable reports whether Postgres aborted the transaction because of
+// a conflict with a concurrent one, which may succeed when run again.
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+func txFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok
+}
+
+// conn returns the transaction of the context if there is one.
+func conn(ctx context.Context, db *sqlx.DB) queryer {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+
+	return db
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxManager_RunInTx(t *testing.T) {
+	t.Run("commits", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		before := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectExec(regexp.QuoteMeta(purgeCarsQuery)).
+			WithArgs(before, "", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.mock.ExpectCommit()
+		tm := NewTxManager(f.db)
+		repo := New(f.db)
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			_, err := repo.PurgeDeletedCars(ctx, before)
+			return err
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		expectedErr := errors.New("stop")
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectRollback()
+		tm := NewTxManager(f.db)
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("joins outer transaction", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectCommit()
+		tm := NewTxManager(f.db)
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			return tm.RunInTx(ctx, func(inner context.Context) error {
+				outerTx, _ := txFromContext(ctx)
+				innerTx, _ := txFromContext(inner)
+				assert.Same(t, outerTx, innerTx)
+				return nil
+			})
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("retries serialization failures", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectCommit().WillReturnError(&pq.Error{Code: "40001"})
+		f.mock.ExpectBegin()
+		f.mock.ExpectCommit()
+		tm := NewTxManager(f.db, WithBackoff(0))
+		runs := 0
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			runs++
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 2, runs)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		deadlock := &pq.Error{Code: "40P01"}
+
+		for i := 0; i < 2; i++ {
+			f.mock.ExpectBegin()
+			f.mock.ExpectRollback()
+		}
+		tm := NewTxManager(f.db, WithMaxAttempts(2), WithBackoff(0))
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			return deadlock
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, deadlock)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		defer f.Teardown()
+		uniqueViolation := &pq.Error{Code: "23505"}
+
+		f.mock.ExpectBegin()
+		f.mock.ExpectRollback()
+		tm := NewTxManager(f.db, WithBackoff(0))
+
+		// Act
+		err := tm.RunInTx(context.Background(), func(ctx context.Context) error {
+			return uniqueViolation
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, uniqueViolation)
+		assert.NoError(t, f.mock.ExpectationsWereMet())
+	})
+}
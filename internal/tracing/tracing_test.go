@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("otlp exporter sends spans to the collector", func(t *testing.T) {
+		// Arrange
+		type export struct {
+			path        string
+			contentType string
+			size        int
+		}
+		exports := make(chan export, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			exports <- export{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), size: len(body)}
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		}))
+		defer collector.Close()
+		cfg := config.Tracing{
+			Exporter:    ExporterOTLP,
+			Endpoint:    strings.TrimPrefix(collector.URL, "http://"),
+			Insecure:    true,
+			ServiceName: "test",
+			SampleRatio: 1,
+		}
+
+		// Act
+		shutdown, err := New(context.Background(), cfg)
+		assert.NoError(t, err)
+		_, span := Start(context.Background(), "test span")
+		span.End()
+		err = shutdown(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		got := <-exports
+		assert.Equal(t, "/v1/traces", got.path)
+		assert.Equal(t, "application/x-protobuf", got.contentType)
+		assert.NotZero(t, got.size)
+	})
+
+	t.Run("stdout exporter", func(t *testing.T) {
+		// Act
+		shutdown, err := New(context.Background(), config.Tracing{Exporter: ExporterStdout, SampleRatio: 1})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("tracing disabled", func(t *testing.T) {
+		// Act
+		shutdown, err := New(context.Background(), config.Tracing{Exporter: ExporterNone})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		// Act
+		_, err := New(context.Background(), config.Tracing{Exporter: "zipkin"})
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestEnd(t *testing.T) {
+	// Arrange
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	returnErr := errors.New("text string")
+
+	// Act
+	_, ok := Start(context.Background(), "ok")
+	End(ok, new(error))
+	_, failed := Start(context.Background(), "failed")
+	End(failed, &returnErr)
+
+	// Assert
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "text string", spans[1].Status().Description)
+}
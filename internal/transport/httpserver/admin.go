@@ -0,0 +1,134 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// getAPIKeys godoc
+// @Summary      Get API keys
+// @Description  Get all API keys including revoked ones, without their secrets
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   APIKeyDto
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/api-keys [get]
+func (s *Server) getAPIKeys() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := s.keys.GetAPIKeys(r.Context())
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		dto := make([]APIKeyDto, 0, len(keys))
+		for _, k := range keys {
+			dto = append(dto, apiKeyDomainToDto(k))
+		}
+
+		resp, err := json.Marshal(dto)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+// createAPIKey godoc
+// @Summary      Create an API key
+// @Description  Create an API key with a role. The key is returned only in this response.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request    body      NewAPIKeyDto  true  "API key"
+// @Success      201  {object}  CreatedAPIKeyDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/api-keys [post]
+func (s *Server) createAPIKey() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		defer r.Body.Close()
+
+		req := NewAPIKeyDto{}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		key, secret, err := s.keys.CreateAPIKey(r.Context(), req.Name, entities.Role(req.Role))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(CreatedAPIKeyDto{APIKeyDto: apiKeyDomainToDto(key), Key: secret})
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(resp)
+	}
+}
+
+// revokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Revoke an API key by ID, requests with it are rejected from now on
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "API key ID"
+// @Success      204
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /admin/api-keys/{id} [delete]
+func (s *Server) revokeAPIKey() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		if err := s.keys.RevokeAPIKey(r.Context(), id); err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,99 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gihub.com/gibiw/api-example/internal/audit"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type principalCtxKey struct{}
+
+func withPrincipal(ctx context.Context, p entities.Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+func principalFromContext(ctx context.Context) (entities.Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(entities.Principal)
+	return p, ok
+}
+
+// authenticate resolves the bearer token or, without one, the API key of
+// the request to a principal. Requests without valid credentials are
+// rejected, as are requests with credentials of a kind that is not configured.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.principal(r)
+		if err != nil {
+			if errors.Is(err, entities.ErrUnauthenticated) {
+				s.unauthorized(w, r, err)
+				return
+			}
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		ctx := withPrincipal(r.Context(), p)
+		ctx = audit.WithOrigin(ctx, audit.Origin{Actor: p.Id, RequestId: middleware.GetReqID(ctx)})
+		ctx = logger.WithRecord(ctx, logger.FromContext(ctx).WithField("principal_id", p.Id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (s *Server) principal(r *http.Request) (entities.Principal, error) {
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return entities.Principal{}, fmt.Errorf("%w: unsupported authorization scheme", entities.ErrUnauthenticated)
+		}
+		if s.tokens == nil {
+			return entities.Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", entities.ErrUnauthenticated)
+		}
+		return s.tokens.Validate(r.Context(), token)
+	}
+
+	if s.keys == nil {
+		return entities.Principal{}, fmt.Errorf("%w: api keys are not accepted", entities.ErrUnauthenticated)
+	}
+
+	return s.keys.Authenticate(r.Context(), r.Header.Get(apiKeyHeader))
+}
+
+// requireScope lets only principals holding the scope through. It has to
+// run after authenticate.
+func requireScope(scope entities.Scope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			p, ok := principalFromContext(r.Context())
+			if !ok {
+				newErrorResponse(w, r, entities.ErrUnauthenticated)
+				return
+			}
+			if !p.HasScope(scope) {
+				newErrorResponse(w, r, fmt.Errorf("%w: %s scope is required", entities.ErrForbidden, scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func (s *Server) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	if s.tokens != nil {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="cars"`)
+	}
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`APIKey header="%s"`, apiKeyHeader))
+	newErrorResponse(w, r, err)
+}
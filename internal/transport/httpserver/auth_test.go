@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAPIKeys knows a fixed set of secrets.
+type stubAPIKeys map[string]entities.Role
+
+func (k stubAPIKeys) Authenticate(_ context.Context, secret string) (entities.Principal, error) {
+	role, ok := k[secret]
+	if !ok {
+		return entities.Principal{}, entities.ErrUnauthenticated
+	}
+
+	return entities.Principal{Id: secret, Scopes: role.Scopes()}, nil
+}
+
+func (k stubAPIKeys) CreateAPIKey(_ context.Context, name string, role entities.Role) (entities.APIKey, string, error) {
+	return entities.APIKey{Id: uuid.New(), Name: name, Role: role}, "ak_secret", nil
+}
+
+func (k stubAPIKeys) GetAPIKeys(context.Context) ([]entities.APIKey, error) {
+	return []entities.APIKey{}, nil
+}
+
+func (k stubAPIKeys) RevokeAPIKey(context.Context, uuid.UUID) error {
+	return nil
+}
+
+// stubTokens accepts tokens named after the scope they carry.
+type stubTokens struct{}
+
+func (stubTokens) Validate(_ context.Context, token string) (entities.Principal, error) {
+	if token == "expired" {
+		return entities.Principal{}, entities.ErrUnauthenticated
+	}
+
+	return entities.Principal{Id: "user-1", Scopes: []entities.Scope{entities.Scope(token)}}, nil
+}
+
+func TestServer_Authorization(t *testing.T) {
+	keys := stubAPIKeys{
+		"reader": entities.RoleReader,
+		"editor": entities.RoleEditor,
+		"admin":  entities.RoleAdmin,
+	}
+
+	tests := []struct {
+		name   string
+		keys   apiKeys
+		tokens tokenValidator
+		key    string
+		bearer string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"without key", keys, nil, "", "", http.MethodGet, "/admin/api-keys", http.StatusUnauthorized, "unauthenticated"},
+		{"with unknown key", keys, nil, "unknown", "", http.MethodGet, "/admin/api-keys", http.StatusUnauthorized, "unauthenticated"},
+		{"without key store", nil, nil, "admin", "", http.MethodGet, "/cars/" + uuid.NewString(), http.StatusUnauthorized, "unauthenticated"},
+		{"reader deletes car", keys, nil, "reader", "", http.MethodDelete, "/cars/" + uuid.NewString(), http.StatusForbidden, "forbidden"},
+		{"editor restores car", keys, nil, "editor", "", http.MethodPost, "/cars/" + uuid.NewString() + "/restore", http.StatusForbidden, "forbidden"},
+		{"editor lists deleted cars", keys, nil, "editor", "", http.MethodGet, "/cars?include_deleted=true", http.StatusForbidden, "forbidden"},
+		{"editor manages keys", keys, nil, "editor", "", http.MethodGet, "/admin/api-keys", http.StatusForbidden, "forbidden"},
+		{"admin lists keys", keys, nil, "admin", "", http.MethodGet, "/admin/api-keys", http.StatusOK, ""},
+		{"admin creates key", keys, nil, "admin", "", http.MethodPost, "/admin/api-keys", http.StatusCreated, ""},
+		{"bearer token with scope", keys, stubTokens{}, "", "api-keys:manage", http.MethodGet, "/admin/api-keys", http.StatusOK, ""},
+		{"bearer token without scope", keys, stubTokens{}, "", "cars:read", http.MethodGet, "/admin/api-keys", http.StatusForbidden, "forbidden"},
+		{"invalid bearer token", keys, stubTokens{}, "", "expired", http.MethodGet, "/admin/api-keys", http.StatusUnauthorized, "unauthenticated"},
+		{"bearer token not accepted", keys, nil, "admin", "api-keys:manage", http.MethodGet, "/admin/api-keys", http.StatusUnauthorized, "unauthenticated"},
+		{"health is public", nil, nil, "", "", http.MethodGet, "/healthz", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var opts []Option
+			if tt.keys != nil {
+				opts = append(opts, WithAPIKeys(tt.keys))
+			}
+			if tt.tokens != nil {
+				opts = append(opts, WithTokenValidator(tt.tokens))
+			}
+			h := New(config.Service{}, nil, opts...).addHandlers()
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.method == http.MethodPost {
+				r = httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name": "importer", "role": "editor"}`))
+			}
+			if tt.key != "" {
+				r.Header.Set(apiKeyHeader, tt.key)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				resp := problemResponse{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, tt.code, resp.Code)
+			}
+			if tt.status == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+)
+
+// batchCars godoc
+// @Summary      Run a batch of car operations
+// @Description  Create, update and delete cars in one transaction. Operations run in order and the first failed one rolls back the whole batch.
+// @Tags         cars
+// @Accept       json
+// @Produce      json
+// @Param        request    body      BatchRequestDto  true  "Operations"
+// @Success      200  {object}  BatchResponseDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      404  {object}  BatchResponseDto
+// @Failure      409  {object}  BatchResponseDto
+// @Failure      412  {object}  BatchResponseDto
+// @Failure      422  {object}  BatchResponseDto
+// @Failure      428  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/batch [post]
+func (s *Server) batchCars() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		defer r.Body.Close()
+
+		req := BatchRequestDto{}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		ops := make([]entities.CarOperation, 0, len(req.Operations))
+		for i, dto := range req.Operations {
+			op, err := carOperationToDomain(dto)
+			if err != nil {
+				newErrorResponse(w, r, fmt.Errorf("operation %d: %w", i, err))
+				return
+			}
+			ops = append(ops, op)
+		}
+
+		res, err := s.usc.BatchCars(r.Context(), ops)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		dto := BatchResponseDto{Committed: res.Committed, Results: make([]OperationResultDto, 0, len(ops))}
+		status := http.StatusOK
+		for i, op := range ops {
+			result := OperationResultDto{Op: string(op.Kind), Status: http.StatusFailedDependency}
+			if i < len(res.Results) {
+				result = operationResultDomainToDto(r, op, res.Results[i], res.Committed)
+			}
+			if result.Error != nil {
+				status = result.Status
+			}
+			dto.Results = append(dto.Results, result)
+		}
+
+		resp, err := json.Marshal(dto)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write(resp)
+	}
+}
+
+func carOperationToDomain(dto CarOperationDto) (entities.CarOperation, error) {
+	op := entities.CarOperation{Kind: entities.OperationKind(dto.Op)}
+	if !op.Kind.IsValid() {
+		return entities.CarOperation{}, badRequest(fmt.Errorf("unknown op %q", dto.Op))
+	}
+
+	if op.Kind != entities.OperationDelete {
+		if dto.Car == nil {
+			return entities.CarOperation{}, badRequest(fmt.Errorf("%s needs a car", op.Kind))
+		}
+		op.Car = newCarToDomain(*dto.Car)
+	}
+
+	if op.Kind != entities.OperationCreate {
+		if dto.Id == nil {
+			return entities.CarOperation{}, badRequest(fmt.Errorf("%s needs an id", op.Kind))
+		}
+		version, err := parseETag(dto.IfMatch)
+		if err != nil {
+			return entities.CarOperation{}, err
+		}
+		op.Car.Id = *dto.Id
+		op.Car.Version = version
+	}
+
+	return op, nil
+}
+
+// operationResultDomainToDto gives the result the status the operation would
+// have had as a single request. Successful operations of a batch that was
+// rolled back failed because of another operation.
+func operationResultDomainToDto(r *http.Request, op entities.CarOperation, res entities.CarOperationResult, committed bool) OperationResultDto {
+	dto := OperationResultDto{Op: string(op.Kind)}
+	switch {
+	case res.Err != nil:
+		problem := newProblem(r, res.Err)
+		dto.Status = problem.Status
+		dto.Error = &problem
+	case !committed:
+		dto.Status = http.StatusFailedDependency
+	case op.Kind == entities.OperationDelete:
+		dto.Status = http.StatusNoContent
+	default:
+		dto.Status = http.StatusOK
+		if op.Kind == entities.OperationCreate {
+			dto.Status = http.StatusCreated
+		}
+		car := carDomainToDto(res.Car)
+		dto.Car = &car
+		dto.ETag = formatETag(res.Car.Version)
+	}
+
+	return dto
+}
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubBatch runs the operations until one of them is for a car with a stale
+// version, which fails with a conflict.
+type stubBatch struct {
+	usecases
+	ops []entities.CarOperation
+}
+
+func (u *stubBatch) BatchCars(_ context.Context, ops []entities.CarOperation) (entities.BatchResult, error) {
+	u.ops = ops
+	res := entities.BatchResult{}
+	for _, op := range ops {
+		if op.Kind != entities.OperationCreate && op.Car.Version != 1 {
+			res.Results = append(res.Results, entities.CarOperationResult{Err: entities.ErrConflict})
+			return res, nil
+		}
+		car := op.Car
+		car.Version++
+		if op.Kind == entities.OperationDelete {
+			car = entities.Car{}
+		}
+		res.Results = append(res.Results, entities.CarOperationResult{Car: car})
+	}
+	res.Committed = true
+
+	return res, nil
+}
+
+func TestServer_BatchCars(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"editor": entities.RoleEditor})
+	id := uuid.New()
+	car := `{"brand":"Audi","model":"A3","color":"Red","cost":10000}`
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		committed bool
+		statuses  []int
+		code      string
+	}{
+		{
+			name:      "committed",
+			body:      `{"operations":[{"op":"create","car":` + car + `},{"op":"update","id":"` + id.String() + `","if_match":"\"1\"","car":` + car + `},{"op":"delete","id":"` + id.String() + `","if_match":"\"1\""}]}`,
+			status:    http.StatusOK,
+			committed: true,
+			statuses:  []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+		},
+		{
+			name:     "rolled back",
+			body:     `{"operations":[{"op":"create","car":` + car + `},{"op":"delete","id":"` + id.String() + `","if_match":"\"2\""},{"op":"create","car":` + car + `}]}`,
+			status:   http.StatusConflict,
+			statuses: []int{http.StatusFailedDependency, http.StatusConflict, http.StatusFailedDependency},
+		},
+		{
+			name:   "unknown op",
+			body:   `{"operations":[{"op":"upsert","car":` + car + `}]}`,
+			status: http.StatusBadRequest,
+			code:   "bad_request",
+		},
+		{
+			name:   "update without if match",
+			body:   `{"operations":[{"op":"update","id":"` + id.String() + `","car":` + car + `}]}`,
+			status: http.StatusPreconditionRequired,
+			code:   "precondition_required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			h := New(config.Service{}, &stubBatch{}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodPost, "/cars/batch", strings.NewReader(tt.body))
+			r.Header.Set(apiKeyHeader, "editor")
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				resp := problemResponse{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, tt.code, resp.Code)
+				return
+			}
+			resp := BatchResponseDto{}
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.committed, resp.Committed)
+			statuses := []int{}
+			for _, res := range resp.Results {
+				statuses = append(statuses, res.Status)
+			}
+			assert.Equal(t, tt.statuses, statuses)
+		})
+	}
+}
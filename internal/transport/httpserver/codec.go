@@ -0,0 +1,280 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const csvMediaType = "text/csv"
+
+var errNotAcceptable = errors.New("not acceptable")
+
+// codec encodes and decodes car bodies in one media type. Aliases are
+// other media types in use for the same format.
+type codec struct {
+	mediaType string
+	aliases   []string
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+func (c codec) matches(mediaType string) bool {
+	if c.mediaType == mediaType {
+		return true
+	}
+	for _, a := range c.aliases {
+		if a == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// codecs are the supported formats, JSON first as the default.
+var codecs = []codec{
+	{
+		mediaType: "application/json",
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
+	},
+	{
+		mediaType: csvMediaType,
+		marshal:   marshalCSV,
+		unmarshal: unmarshalCSV,
+	},
+	{
+		mediaType: "application/xml",
+		aliases:   []string{"text/xml"},
+		marshal:   xml.Marshal,
+		unmarshal: xml.Unmarshal,
+	},
+	{
+		mediaType: "application/yaml",
+		aliases:   []string{"application/x-yaml", "text/yaml"},
+		marshal:   yaml.Marshal,
+		unmarshal: yaml.Unmarshal,
+	},
+	{
+		mediaType: "application/msgpack",
+		aliases:   []string{"application/x-msgpack", "application/vnd.msgpack"},
+		marshal:   marshalMsgpack,
+		unmarshal: unmarshalMsgpack,
+	},
+}
+
+// negotiate picks the codec of the response among offers from the Accept
+// header of the request, preferring the media ranges with the higher
+// quality. A missing header accepts the first offer.
+func negotiate(r *http.Request, offers []codec) (codec, error) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0], nil
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, c := range offers {
+			if mediaRange == "*/*" || c.matches(mediaRange) ||
+				strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(c.mediaType, strings.TrimSuffix(mediaRange, "*")) {
+				return c, nil
+			}
+		}
+	}
+
+	return codec{}, fmt.Errorf("%w: %q, use one of %s", errNotAcceptable, accept, supportedMediaTypes(offers))
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by
+// quality. Ranges with quality 0 are left out.
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	mediaTypes := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		mediaTypes = append(mediaTypes, r.mediaType)
+	}
+
+	return mediaTypes
+}
+
+// requestCodec picks the codec of the request body among offers from its
+// Content-Type header. A body without a content type is read with the first
+// offer.
+func requestCodec(r *http.Request, offers []codec) (codec, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return offers[0], nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return codec{}, fmt.Errorf("%w: %q", errUnsupportedMediaType, contentType)
+	}
+	for _, c := range offers {
+		if c.matches(mediaType) {
+			return c, nil
+		}
+	}
+
+	return codec{}, fmt.Errorf("%w: %q, use one of %s", errUnsupportedMediaType, mediaType, supportedMediaTypes(offers))
+}
+
+func supportedMediaTypes(offers []codec) string {
+	mediaTypes := make([]string, 0, len(offers))
+	for _, c := range offers {
+		mediaTypes = append(mediaTypes, c.mediaType)
+	}
+
+	return strings.Join(mediaTypes, ", ")
+}
+
+// setContentType marks a negotiated response. Vary tells caches the body
+// depends on the Accept header.
+func setContentType(w http.ResponseWriter, c codec) {
+	w.Header().Set("Content-Type", c.mediaType)
+	w.Header().Add("Vary", "Accept")
+}
+
+var carCSVHeader = []string{"id", "brand", "model", "color", "cost"}
+
+// marshalCSV writes cars as rows under a header row. The next cursor of a
+// page does not fit into CSV, clients follow the Link header instead.
+func marshalCSV(v interface{}) ([]byte, error) {
+	var cars []CarDto
+	switch v := v.(type) {
+	case CarDto:
+		cars = []CarDto{v}
+	case CarsPageDto:
+		cars = v.Cars
+	default:
+		return nil, fmt.Errorf("can not encode %T as CSV", v)
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write(carCSVHeader)
+	for _, c := range cars {
+		w.Write(carCSVRecord(c))
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+func carCSVRecord(c CarDto) []string {
+	return []string{c.Id.String(), c.Brand, c.Model, c.Color, strconv.FormatUint(c.Cost, 10)}
+}
+
+// unmarshalCSV reads one car from a header row and a value row. Columns are
+// matched by name, so their order does not matter.
+func unmarshalCSV(data []byte, v interface{}) error {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) != 2 {
+		return fmt.Errorf("expected a header row and one car, got %d rows", len(records))
+	}
+
+	fields := csvFields(records[0], records[1])
+	car, err := newCarFromCSV(fields)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case *NewCarDto:
+		*v = car
+	case *CarDto:
+		*v = CarDto{Brand: car.Brand, Model: car.Model, Color: car.Color, Cost: car.Cost}
+		if id, ok := fields["id"]; ok {
+			if v.Id, err = uuid.Parse(id); err != nil {
+				return fmt.Errorf("invalid id %q", id)
+			}
+		}
+	default:
+		return fmt.Errorf("can not decode CSV into %T", v)
+	}
+
+	return nil
+}
+
+// csvFields maps the values of a record to the lower-cased names of the
+// header row.
+func csvFields(header, record []string) map[string]string {
+	fields := make(map[string]string, len(header))
+	for i, name := range header {
+		if i < len(record) {
+			fields[strings.ToLower(strings.TrimSpace(name))] = record[i]
+		}
+	}
+
+	return fields
+}
+
+func newCarFromCSV(fields map[string]string) (NewCarDto, error) {
+	car := NewCarDto{Brand: fields["brand"], Model: fields["model"], Color: fields["color"]}
+	if cost, ok := fields["cost"]; ok {
+		var err error
+		if car.Cost, err = strconv.ParseUint(cost, 10, 64); err != nil {
+			return NewCarDto{}, fmt.Errorf("invalid cost %q", cost)
+		}
+	}
+
+	return car, nil
+}
+
+// marshalMsgpack encodes structs with the names of their JSON fields.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unmarshalMsgpack(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
@@ -0,0 +1,150 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		mediaType string
+		err       error
+	}{
+		{"without accept", "", "application/json", nil},
+		{"any", "*/*", "application/json", nil},
+		{"csv", "text/csv", "text/csv", nil},
+		{"alias", "application/x-yaml", "application/yaml", nil},
+		{"type wildcard", "text/*", "text/csv", nil},
+		{"by quality", "application/xml;q=0.5, application/msgpack", "application/msgpack", nil},
+		{"excluded", "text/csv;q=0, application/xml", "application/xml", nil},
+		{"unsupported", "image/png", "", errNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			r := httptest.NewRequest(http.MethodGet, "/cars/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			// Act
+			c, err := negotiate(r, codecs)
+
+			// Assert
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.mediaType, c.mediaType)
+		})
+	}
+}
+
+func TestRequestCodec(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		mediaType   string
+		err         error
+	}{
+		{"without content type", "", "application/json", nil},
+		{"with charset", "application/json; charset=utf-8", "application/json", nil},
+		{"alias", "text/xml", "application/xml", nil},
+		{"unsupported", "text/plain", "", errUnsupportedMediaType},
+		{"invalid", "text/", "", errUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			r := httptest.NewRequest(http.MethodPost, "/cars/", nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			// Act
+			c, err := requestCodec(r, codecs)
+
+			// Assert
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.mediaType, c.mediaType)
+		})
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	car := CarDto{
+		Id:    uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"),
+		Brand: "BMW",
+		Model: "X5",
+		Color: "black",
+		Cost:  100000,
+	}
+
+	for _, c := range codecs {
+		t.Run(c.mediaType, func(t *testing.T) {
+			// Act
+			data, err := c.marshal(car)
+			decoded := CarDto{}
+			decodeErr := c.unmarshal(data, &decoded)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NoError(t, decodeErr)
+			decoded.XMLName = car.XMLName
+			assert.Equal(t, car, decoded)
+		})
+	}
+}
+
+func TestMarshalCSV(t *testing.T) {
+	// Arrange
+	page := CarsPageDto{
+		Cars: []CarDto{
+			{Id: uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"), Brand: "BMW", Model: "X5", Color: "black", Cost: 100000},
+			{Id: uuid.MustParse("5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f"), Brand: "Lada", Model: "Niva, 4x4", Color: "white", Cost: 15000},
+		},
+		NextCursor: "next",
+	}
+
+	// Act
+	data, err := marshalCSV(page)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "id,brand,model,color,cost\n"+
+		"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c,BMW,X5,black,100000\n"+
+		"5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f,Lada,\"Niva, 4x4\",white,15000\n", string(data))
+}
+
+func TestUnmarshalCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		car  NewCarDto
+		err  bool
+	}{
+		{"columns in any order", "cost,color,brand,model\n100000,black,BMW,X5\n", NewCarDto{Brand: "BMW", Model: "X5", Color: "black", Cost: 100000}, false},
+		{"without rows", "brand,model,color,cost\n", NewCarDto{}, true},
+		{"with several rows", "brand,cost\nBMW,1\nAudi,2\n", NewCarDto{}, true},
+		{"with invalid cost", "brand,cost\nBMW,cheap\n", NewCarDto{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			car := NewCarDto{}
+
+			// Act
+			err := unmarshalCSV([]byte(tt.data), &car)
+
+			// Assert
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.car, car)
+		})
+	}
+}
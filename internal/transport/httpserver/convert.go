@@ -13,6 +13,17 @@ func newCarToDomain(nc NewCarDto) entities.Car {
 
 func carDomainToDto(c entities.Car) CarDto {
 	return CarDto{
+		Id:        c.Id,
+		Brand:     c.Brand,
+		Model:     c.Model,
+		Color:     c.Color,
+		Cost:      c.Cost,
+		DeletedAt: c.DeletedAt,
+	}
+}
+
+func carToDomain(c CarDto) entities.Car {
+	return entities.Car{
 		Id:    c.Id,
 		Brand: c.Brand,
 		Model: c.Model,
@@ -21,12 +32,81 @@ func carDomainToDto(c entities.Car) CarDto {
 	}
 }
 
-func carToDomain(c CarDto) entities.Car {
-	return entities.Car{
-		Id:    c.Id,
+func carDomainToNewDto(c entities.Car) NewCarDto {
+	return NewCarDto{
 		Brand: c.Brand,
 		Model: c.Model,
 		Color: c.Color,
 		Cost:  c.Cost,
 	}
 }
+
+func apiKeyDomainToDto(k entities.APIKey) APIKeyDto {
+	return APIKeyDto{
+		Id:        k.Id,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Role:      string(k.Role),
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+func importReportDomainToDto(r entities.ImportReport) ImportReportDto {
+	dto := ImportReportDto{
+		Accepted:   r.Accepted,
+		Rejected:   make([]RejectedRowDto, 0, len(r.Rejected)),
+		RolledBack: r.RolledBack,
+	}
+	for _, row := range r.Rejected {
+		rejected := RejectedRowDto{Row: row.Row, Reason: row.Reason}
+		for _, f := range row.Fields {
+			rejected.Errors = append(rejected.Errors, fieldErrorResponse{Field: f.Field, Message: f.Message})
+		}
+		dto.Rejected = append(dto.Rejected, rejected)
+	}
+
+	return dto
+}
+
+func carRevisionDomainToDto(r entities.CarRevision) CarRevisionDto {
+	return CarRevisionDto{
+		Revision:  r.Revision,
+		Operation: string(r.Operation),
+		Old:       carValuesDomainToDto(r.Old),
+		New:       carValuesDomainToDto(r.New),
+		Actor:     r.Actor,
+		RequestId: r.RequestId,
+		ChangedAt: r.ChangedAt,
+	}
+}
+
+func carValuesDomainToDto(v *entities.CarValues) *CarValuesDto {
+	if v == nil {
+		return nil
+	}
+
+	return &CarValuesDto{
+		Brand:     v.Brand,
+		Model:     v.Model,
+		Color:     v.Color,
+		Cost:      v.Cost,
+		DeletedAt: v.DeletedAt,
+	}
+}
+
+func carRevisionDiffDomainToDto(r entities.CarRevision) CarRevisionDiffDto {
+	dto := CarRevisionDiffDto{
+		Revision:  r.Revision,
+		Operation: string(r.Operation),
+		Actor:     r.Actor,
+		RequestId: r.RequestId,
+		ChangedAt: r.ChangedAt,
+		Changes:   []FieldChangeDto{},
+	}
+	for _, c := range r.Diff() {
+		dto.Changes = append(dto.Changes, FieldChangeDto{Field: c.Field, Old: c.Old, New: c.New})
+	}
+
+	return dto
+}
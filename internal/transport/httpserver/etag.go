@@ -0,0 +1,47 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+)
+
+// errPreconditionRequired is returned when a write comes without If-Match.
+var errPreconditionRequired = errors.New("If-Match header is required")
+
+func formatETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func setETag(w http.ResponseWriter, c entities.Car) {
+	w.Header().Set("ETag", formatETag(c.Version))
+}
+
+// parseIfMatch returns the car version the client based its write on. Only a
+// single strong ETag issued by this service is accepted.
+func parseIfMatch(r *http.Request) (int64, error) {
+	return parseETag(r.Header.Get("If-Match"))
+}
+
+func parseETag(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, errPreconditionRequired
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, badRequest(fmt.Errorf("malformed If-Match header %q", value))
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("If-Match %s: %w", value, entities.ErrVersionMismatch)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,195 @@
+package httpserver
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+)
+
+const (
+	ndjsonMediaType = "application/x-ndjson"
+	// exportFlushRows is how many rows are written between two flushes, so
+	// clients see progress without a flush per row.
+	exportFlushRows = 100
+)
+
+// exportCodecs are the formats an export can be streamed in. Only their
+// media types are used, rows are written by a carWriter.
+var exportCodecs = []codec{
+	{mediaType: ndjsonMediaType, aliases: []string{"application/jsonl"}},
+	{mediaType: csvMediaType},
+}
+
+// carWriter writes cars one by one into a buffer and hands the buffer to the
+// underlying writer on Flush.
+type carWriter interface {
+	Write(car CarDto) error
+	Flush() error
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w ndjsonWriter) Write(car CarDto) error {
+	return w.enc.Encode(car)
+}
+
+func (w ndjsonWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+type csvWriter struct {
+	csv *csv.Writer
+}
+
+func (w csvWriter) Write(car CarDto) error {
+	return w.csv.Write(carCSVRecord(car))
+}
+
+func (w csvWriter) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// newCarWriter returns the writer of the media type and the file extension
+// for it. A CSV starts with the header row.
+func newCarWriter(mediaType string, w io.Writer) (carWriter, string) {
+	if mediaType == csvMediaType {
+		cw := csv.NewWriter(w)
+		cw.Write(carCSVHeader)
+		return csvWriter{csv: cw}, "csv"
+	}
+
+	buf := bufio.NewWriter(w)
+	return ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}, "ndjson"
+}
+
+// exportCars godoc
+// @Summary      Export cars
+// @Description  Stream all cars matching the filters as NDJSON or CSV, sorted like the list of cars
+// @Tags         cars
+// @Accept       json
+// @Produce      application/x-ndjson,text/csv
+// @Param        brand     query     string  false  "Brand"
+// @Param        model     query     string  false  "Model"
+// @Param        color     query     string  false  "Color"
+// @Param        min_cost  query     int     false  "Minimal cost"
+// @Param        max_cost  query     int     false  "Maximal cost"
+// @Param        sort      query     string  false  "Sort field"  Enums(id, brand, model, color, cost)
+// @Param        order     query     string  false  "Sort order"  Enums(asc, desc)
+// @Param        include_deleted  query  bool  false  "Include deleted cars, needs the cars:admin scope"
+// @Success      200  {array}   CarDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/export [get]
+func (s *Server) exportCars() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, exportCodecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		// An export has no pages, so limit and cursor do not apply.
+		q := r.URL.Query()
+		q.Del("limit")
+		q.Del("cursor")
+		filter, err := parseCarFilter(q)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		if filter.IncludeDeleted, err = parseIncludeDeleted(r); err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		// A large export takes longer than the write timeout of the server,
+		// so the timeout applies to every flush instead. A stalled client then
+		// ends the export, which holds a database connection while it runs.
+		rc := http.NewResponseController(w)
+		extendDeadline := func() error {
+			deadline := time.Time{}
+			if s.cfg.WriteTimeoutSeconds > 0 {
+				deadline = time.Now().Add(time.Second * time.Duration(s.cfg.WriteTimeoutSeconds))
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		}
+		if err := extendDeadline(); err != nil {
+			logger.FromContext(r.Context()).Warn("can not extend write deadline: ", err)
+		}
+
+		cw, ext := newCarWriter(enc.mediaType, w)
+		started := false
+		start := func() {
+			started = true
+			setContentType(w, enc)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"cars.%s\"", ext))
+			w.WriteHeader(http.StatusOK)
+		}
+		flush := func() error {
+			if err := extendDeadline(); err != nil {
+				return err
+			}
+			if err := cw.Flush(); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		}
+
+		rows := 0
+		err = s.usc.ExportCars(r.Context(), filter, func(c entities.Car) error {
+			if !started {
+				start()
+			}
+			if err := cw.Write(carDomainToDto(c)); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushRows == 0 {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			if !started {
+				start()
+			}
+			err = flush()
+		}
+		if err == nil {
+			return
+		}
+
+		if !started {
+			newErrorResponse(w, r, err)
+			return
+		}
+		// The status has been sent already. Aborting the response lets the
+		// client tell a failed export from a complete one.
+		logger.FromContext(r.Context()).Error("export aborted after ", rows, " rows: ", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,192 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	appmetrics "gihub.com/gibiw/api-example/internal/metrics"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubExport exports a fixed list of cars and then fails with err.
+type stubExport struct {
+	usecases
+	cars   []entities.Car
+	err    error
+	filter entities.CarFilter
+}
+
+func (u *stubExport) ExportCars(_ context.Context, filter entities.CarFilter, fn func(entities.Car) error) error {
+	u.filter = filter
+	for _, c := range u.cars {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	return u.err
+}
+
+func TestServer_ExportCars(t *testing.T) {
+	cars := []entities.Car{
+		{Id: uuid.MustParse("bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c"), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000},
+		{Id: uuid.MustParse("5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f"), Brand: "Audi", Model: "A4", Color: "Blue", Cost: 20000},
+	}
+	keys := WithAPIKeys(stubAPIKeys{"reader": entities.RoleReader})
+
+	tests := []struct {
+		name        string
+		accept      string
+		cars        []entities.Car
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "ndjson",
+			accept:      "",
+			cars:        cars,
+			status:      http.StatusOK,
+			contentType: ndjsonMediaType,
+			body: `{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi","model":"A3","color":"Red","cost":10000}` + "\n" +
+				`{"id":"5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f","brand":"Audi","model":"A4","color":"Blue","cost":20000}` + "\n",
+		},
+		{
+			name:        "csv",
+			accept:      "text/csv",
+			cars:        cars,
+			status:      http.StatusOK,
+			contentType: csvMediaType,
+			body: "id,brand,model,color,cost\n" +
+				"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c,Audi,A3,Red,10000\n" +
+				"5d6c3a4e-1b2f-4c8d-9e0a-7f6b5c4d3e2f,Audi,A4,Blue,20000\n",
+		},
+		{
+			name:        "csv without cars",
+			accept:      "text/csv",
+			status:      http.StatusOK,
+			contentType: csvMediaType,
+			body:        "id,brand,model,color,cost\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			usc := &stubExport{cars: tt.cars}
+			h := New(config.Service{}, usc, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodGet, "/cars/export?brand=Audi&limit=5", nil)
+			r.Header.Set(apiKeyHeader, "reader")
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, w.Body.String())
+			assert.Equal(t, "Audi", usc.filter.Brand)
+			assert.Zero(t, usc.filter.Limit)
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		// Arrange
+		h := New(config.Service{}, &stubExport{}, keys).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/export", nil)
+		r.Header.Set(apiKeyHeader, "reader")
+		r.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	})
+
+	t.Run("error before first car", func(t *testing.T) {
+		// Arrange
+		h := New(config.Service{}, &stubExport{err: errors.New("connection refused")}, keys).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/export", nil)
+		r.Header.Set(apiKeyHeader, "reader")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		resp := problemResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "internal_error", resp.Code)
+	})
+
+	t.Run("error after first car", func(t *testing.T) {
+		// Arrange
+		mtr := appmetrics.New(prometheus.NewRegistry())
+		h := New(config.Service{}, &stubExport{cars: cars, err: errors.New("connection reset")}, keys, WithMetrics(mtr)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/export", nil)
+		r.Header.Set(apiKeyHeader, "reader")
+		w := httptest.NewRecorder()
+
+		// Act & Assert
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { h.ServeHTTP(w, r) })
+		assert.Equal(t, http.StatusOK, w.Code)
+		scrape := httptest.NewRecorder()
+		mtr.Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Contains(t, scrape.Body.String(), `http_requests_total{method="GET",route="/cars/export",status="aborted"} 1`)
+	})
+
+	t.Run("stalled client", func(t *testing.T) {
+		// Arrange
+		usc := &stubEndlessExport{done: make(chan error, 1)}
+		srv := httptest.NewServer(New(config.Service{WriteTimeoutSeconds: 1}, usc, keys).addHandlers())
+		defer srv.Close()
+		r, err := http.NewRequest(http.MethodGet, srv.URL+"/cars/export", nil)
+		assert.NoError(t, err)
+		r.Header.Set(apiKeyHeader, "reader")
+
+		// Act
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		// Assert
+		select {
+		case err := <-usc.done:
+			assert.Error(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("export did not end for a client that stopped reading")
+		}
+	})
+}
+
+// stubEndlessExport exports cars until the handler fails to write them.
+type stubEndlessExport struct {
+	usecases
+	done chan error
+}
+
+func (u *stubEndlessExport) ExportCars(_ context.Context, _ entities.CarFilter, fn func(entities.Car) error) error {
+	car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+	for {
+		if err := fn(car); err != nil {
+			u.done <- err
+			return err
+		}
+	}
+}
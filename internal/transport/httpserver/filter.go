@@ -0,0 +1,146 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+)
+
+const maxLimit = 100
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the payload of the opaque cursor handed out to clients. It
+// remembers the ordering so a cursor can not be reused with another one.
+type cursorToken struct {
+	SortBy entities.SortField `json:"s"`
+	Order  entities.SortOrder `json:"o"`
+	Value  string             `json:"v"`
+	Id     uuid.UUID          `json:"id"`
+}
+
+func encodeCursor(filter entities.CarFilter, c entities.Cursor) string {
+	data, _ := json.Marshal(cursorToken{
+		SortBy: filter.SortBy,
+		Order:  filter.Order,
+		Value:  c.Value,
+		Id:     c.Id,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// setNextLink points to the next page with the same query, for formats
+// like CSV that have no room for the cursor in the body.
+func setNextLink(w http.ResponseWriter, r *http.Request, cursor string) {
+	q := r.URL.Query()
+	q.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
+
+func decodeCursor(filter entities.CarFilter, s string) (*entities.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	token := cursorToken{}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	if token.SortBy != filter.SortBy || token.Order != filter.Order {
+		return nil, fmt.Errorf("%w: cursor was issued for another sort order", errInvalidCursor)
+	}
+
+	return &entities.Cursor{Value: token.Value, Id: token.Id}, nil
+}
+
+func parseCarFilter(q url.Values) (entities.CarFilter, error) {
+	filter := entities.CarFilter{
+		Brand:  q.Get("brand"),
+		Model:  q.Get("model"),
+		Color:  q.Get("color"),
+		SortBy: entities.SortById,
+		Order:  entities.OrderAsc,
+	}
+
+	if v := q.Get("sort"); v != "" {
+		filter.SortBy = entities.SortField(v)
+		if !filter.SortBy.IsValid() {
+			return entities.CarFilter{}, fmt.Errorf("invalid sort field %q", v)
+		}
+	}
+
+	if v := q.Get("order"); v != "" {
+		filter.Order = entities.SortOrder(v)
+		if !filter.Order.IsValid() {
+			return entities.CarFilter{}, fmt.Errorf("invalid sort order %q", v)
+		}
+	}
+
+	var err error
+	if filter.MinCost, err = parseCost(q, "min_cost"); err != nil {
+		return entities.CarFilter{}, err
+	}
+	if filter.MaxCost, err = parseCost(q, "max_cost"); err != nil {
+		return entities.CarFilter{}, err
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || limit == 0 || limit > maxLimit {
+			return entities.CarFilter{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if filter.After, err = decodeCursor(filter, v); err != nil {
+			return entities.CarFilter{}, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseIncludeDeleted reads the include_deleted parameter. Only principals
+// with the cars:admin scope may see deleted cars.
+func parseIncludeDeleted(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("include_deleted")
+	if v == "" {
+		return false, nil
+	}
+
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, badRequest(fmt.Errorf("invalid include_deleted %q", v))
+	}
+	if p, _ := principalFromContext(r.Context()); include && !p.HasScope(entities.ScopeCarsAdmin) {
+		return false, fmt.Errorf("%w: %s scope is required to include deleted cars", entities.ErrForbidden, entities.ScopeCarsAdmin)
+	}
+
+	return include, nil
+}
+
+func parseCost(q url.Values, name string) (*uint64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	cost, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, v)
+	}
+
+	return &cost, nil
+}
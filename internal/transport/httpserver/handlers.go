@@ -2,46 +2,79 @@ package httpserver
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
 	"io"
 	"net/http"
 
-	"gihub.com/gibiw/api-example/internal/entities"
-	mycache "github.com/gibiw/cache"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/gookit/slog"
 )
 
 // getCars godoc
-// @Summary      Get all cars
-// @Description  Get all cars
+// @Summary      Get cars
+// @Description  Get a page of cars filtered and sorted by the query parameters
 // @Tags         cars
 // @Accept       json
-// @Produce      json
-// @Success      200  {object}  []CarDto
-// @Failure      500  {object}  errorResponse
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
+// @Param        brand     query     string  false  "Brand"
+// @Param        model     query     string  false  "Model"
+// @Param        color     query     string  false  "Color"
+// @Param        min_cost  query     int     false  "Minimal cost"
+// @Param        max_cost  query     int     false  "Maximal cost"
+// @Param        sort      query     string  false  "Sort field"  Enums(id, brand, model, color, cost)
+// @Param        order     query     string  false  "Sort order"  Enums(asc, desc)
+// @Param        limit     query     int     false  "Page size"
+// @Param        cursor    query     string  false  "Cursor of the next page"
+// @Param        include_deleted  query  bool  false  "Include deleted cars, needs the cars:admin scope"
+// @Success      200  {object}  CarsPageDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /cars/ [get]
 func (s *Server) getCars() func(w http.ResponseWriter, _ *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cars, err := s.usc.GetCars(r.Context())
+		enc, err := negotiate(r, codecs)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
-		dtos := make([]CarDto, 0, len(cars))
-		for _, v := range cars {
-			dtos = append(dtos, carDomainToDto(v))
+		filter, err := parseCarFilter(r.URL.Query())
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		if filter.IncludeDeleted, err = parseIncludeDeleted(r); err != nil {
+			newErrorResponse(w, r, err)
+			return
 		}
 
-		data, err := json.Marshal(dtos)
+		page, err := s.usc.GetCars(r.Context(), filter)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
+		dto := CarsPageDto{Cars: make([]CarDto, 0, len(page.Cars))}
+		for _, v := range page.Cars {
+			dto.Cars = append(dto.Cars, carDomainToDto(v))
+		}
+		if page.Next != nil {
+			dto.NextCursor = encodeCursor(filter, *page.Next)
+			setNextLink(w, r, dto.NextCursor)
+		}
+
+		data, err := enc.marshal(dto)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		setContentType(w, enc)
 		w.WriteHeader(http.StatusOK)
 		w.Write(data)
 	}
@@ -49,48 +82,59 @@ func (s *Server) getCars() func(w http.ResponseWriter, _ *http.Request) {
 
 // getCarById godoc
 // @Summary      Get a car by ID
-// @Description  Get a car by ID
+// @Description  Get a car by ID. Deleted cars are only found with include_deleted.
 // @Tags         cars
 // @Accept       json
-// @Produce      json
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
 // @Param        id   path      string  true  "Car ID"
+// @Param        include_deleted  query  bool  false  "Include deleted cars, needs the cars:admin scope"
 // @Success      200  {object}  CarDto
-// @Failure      500  {object}  errorResponse
+// @Header       200  {string}  ETag  "Version of the car"
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /cars/{id} [get]
 func (s *Server) getCarById() func(w http.ResponseWriter, _ *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
 		idParam := chi.URLParam(r, "id")
 		id, err := uuid.Parse(idParam)
 		if err != nil {
-			newErrorResponse(w, http.StatusBadRequest, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 
-		if value, ok := s.getValueFromCache(idParam); ok {
-			car, err := json.Marshal(carDomainToDto(value))
-			if err != nil {
-				newErrorResponse(w, http.StatusInternalServerError, err)
-				return
-			}
-
-			w.WriteHeader(http.StatusOK)
-			w.Write(car)
+		includeDeleted, err := parseIncludeDeleted(r)
+		if err != nil {
+			newErrorResponse(w, r, err)
 			return
 		}
 
-		c, err := s.usc.GetCarById(r.Context(), id)
+		c, err := s.usc.GetCarById(r.Context(), id, includeDeleted)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
-		s.ch.Set(idParam, c, s.cacheTtl)
 
-		resp, err := json.Marshal(carDomainToDto(c))
+		resp, err := enc.marshal(carDomainToDto(c))
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
+		setContentType(w, enc)
+		setETag(w, c)
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	}
@@ -100,43 +144,64 @@ func (s *Server) getCarById() func(w http.ResponseWriter, _ *http.Request) {
 // @Summary      Add new car
 // @Description  Add new car
 // @Tags         cars
-// @Accept       json
-// @Produce      json
+// @Accept       json,text/csv,xml,application/yaml,application/msgpack
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
 // @Param        request    body      NewCarDto  true  "Car"
 // @Success      201  {object}  CarDto
-// @Failure      500  {object}  errorResponse
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      415  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      409  {object}  problemResponse
+// @Failure      422  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /cars [post]
 func (s *Server) addCar() func(w http.ResponseWriter, _ *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		dec, err := requestCodec(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			newErrorResponse(w, http.StatusBadRequest, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 		defer r.Body.Close()
 
 		car := NewCarDto{}
-		err = json.Unmarshal(body, &car)
+		err = dec.unmarshal(body, &car)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 
 		newCar, err := s.usc.AddCar(r.Context(), newCarToDomain(car))
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
-		s.ch.Set(newCar.Id.String(), newCar, s.cacheTtl)
-
-		resp, err := json.Marshal(carDomainToDto(newCar))
+		resp, err := enc.marshal(carDomainToDto(newCar))
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
+		setContentType(w, enc)
+		setETag(w, newCar)
 		w.WriteHeader(http.StatusCreated)
 		w.Write(resp)
 	}
@@ -144,26 +209,42 @@ func (s *Server) addCar() func(w http.ResponseWriter, _ *http.Request) {
 
 // deleteCarById godoc
 // @Summary      Delete a car by ID
-// @Description  Delete a car by ID
+// @Description  Delete a car by ID. The car can be restored until it is purged after the retention period.
 // @Tags         cars
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Car ID"
+// @Param        id        path      string  true  "Car ID"
+// @Param        If-Match  header    string  true  "ETag of the car"
 // @Success      200
-// @Failure      500  {object}  errorResponse
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      412  {object}  problemResponse
+// @Failure      428  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /cars/{id} [delete]
 func (s *Server) deleteCarById() func(w http.ResponseWriter, _ *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "id")
 		id, err := uuid.Parse(idParam)
 		if err != nil {
-			newErrorResponse(w, http.StatusBadRequest, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 
-		err = s.usc.DeleteCarById(r.Context(), id)
+		version, err := parseIfMatch(r)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		err = s.usc.DeleteCarById(r.Context(), id, version)
+		if err != nil {
+			newErrorResponse(w, r, err)
 			return
 		}
 
@@ -171,64 +252,335 @@ func (s *Server) deleteCarById() func(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// restoreCar godoc
+// @Summary      Restore a deleted car
+// @Description  Undo the delete of a car that has not been purged yet
+// @Tags         cars
+// @Accept       json
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
+// @Param        id        path      string  true  "Car ID"
+// @Param        If-Match  header    string  true  "ETag of the deleted car"
+// @Success      200  {object}  CarDto
+// @Header       200  {string}  ETag  "Version of the car"
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      409  {object}  problemResponse
+// @Failure      412  {object}  problemResponse
+// @Failure      428  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/{id}/restore [post]
+func (s *Server) restoreCar() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		idParam := chi.URLParam(r, "id")
+		id, err := uuid.Parse(idParam)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		version, err := parseIfMatch(r)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		c, err := s.usc.RestoreCar(r.Context(), id, version)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := enc.marshal(carDomainToDto(c))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		setContentType(w, enc)
+		setETag(w, c)
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
 // updateCar godoc
 // @Summary      Update a car
-// @Description  Update a car
+// @Description  Update a car, prefer PUT /cars/{id}
+// @Deprecated
 // @Tags         cars
-// @Accept       json
-// @Produce      json
+// @Accept       json,text/csv,xml,application/yaml,application/msgpack
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
 // @Param        request    body      CarDto  true  "Car"
+// @Param        If-Match   header    string  true  "ETag of the car"
 // @Success      200  {object}  CarDto
-// @Failure      500  {object}  errorResponse
+// @Header       200  {string}  ETag  "Version of the car"
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      415  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      409  {object}  problemResponse
+// @Failure      412  {object}  problemResponse
+// @Failure      422  {object}  problemResponse
+// @Failure      428  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /cars [put]
 func (s *Server) updateCar() func(w http.ResponseWriter, _ *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		dec, err := requestCodec(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			newErrorResponse(w, http.StatusBadRequest, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 		defer r.Body.Close()
 
 		car := CarDto{}
-		err = json.Unmarshal(body, &car)
+		err = dec.unmarshal(body, &car)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, badRequest(err))
 			return
 		}
 
-		newCar, err := s.usc.UpdateCar(r.Context(), carToDomain(car))
+		version, err := parseIfMatch(r)
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
-		s.ch.Set(newCar.Id.String(), newCar, s.cacheTtl)
+		c := carToDomain(car)
+		c.Version = version
+		newCar, err := s.usc.UpdateCar(r.Context(), c)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
 
-		resp, err := json.Marshal(carDomainToDto(newCar))
+		resp, err := enc.marshal(carDomainToDto(newCar))
 		if err != nil {
-			newErrorResponse(w, http.StatusInternalServerError, err)
+			newErrorResponse(w, r, err)
 			return
 		}
 
+		setContentType(w, enc)
+		setETag(w, newCar)
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	}
 }
 
-func (s *Server) getValueFromCache(key string) (entities.Car, bool) {
-	value, err := s.ch.Get(key)
-	if err != nil {
-		if errors.Is(mycache.ErrorExpired, err) {
-			slog.Debug("cache is expired for record with id ", key)
-			s.ch.Delete(key)
-			return entities.Car{}, false
+// replaceCar godoc
+// @Summary      Replace a car
+// @Description  Replace all fields of a car by ID
+// @Tags         cars
+// @Accept       json,text/csv,xml,application/yaml,application/msgpack
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
+// @Param        id         path      string     true  "Car ID"
+// @Param        If-Match   header    string     true  "ETag of the car"
+// @Param        request    body      NewCarDto  true  "Car"
+// @Success      200  {object}  CarDto
+// @Header       200  {string}  ETag  "Version of the car"
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      415  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      412  {object}  problemResponse
+// @Failure      422  {object}  problemResponse
+// @Failure      428  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/{id} [put]
+func (s *Server) replaceCar() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
 		}
 
-		slog.Debug(fmt.Sprintf("can not get record with id %s from cache: %s", key, err))
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
 
-		return entities.Car{}, false
+		dec, err := requestCodec(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		defer r.Body.Close()
+
+		car := NewCarDto{}
+		err = dec.unmarshal(body, &car)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		version, err := parseIfMatch(r)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		c := newCarToDomain(car)
+		c.Id = id
+		c.Version = version
+		newCar, err := s.usc.UpdateCar(r.Context(), c)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := enc.marshal(carDomainToDto(newCar))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		setContentType(w, enc)
+		setETag(w, newCar)
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
 	}
+}
+
+// patchCar godoc
+// @Summary      Patch a car
+// @Description  Change some fields of a car with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// @Tags         cars
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Produce      json,text/csv,xml,application/yaml,application/msgpack
+// @Param        id         path      string  true  "Car ID"
+// @Param        If-Match   header    string  true  "ETag of the car"
+// @Param        request    body      object  true  "Patch"
+// @Success      200  {object}  CarDto
+// @Header       200  {string}  ETag  "Version of the car"
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      406  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      409  {object}  problemResponse
+// @Failure      412  {object}  problemResponse
+// @Failure      415  {object}  problemResponse
+// @Failure      422  {object}  problemResponse
+// @Failure      428  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/{id} [patch]
+func (s *Server) patchCar() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enc, err := negotiate(r, codecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
 
-	return value.(entities.Car), true
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+		defer r.Body.Close()
+
+		version, err := parseIfMatch(r)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		// The patch applies to the stored car, so it is read past the cache
+		// that may hold an older version when several instances write.
+		// UpdateCar checks the version under the row lock.
+		current, err := s.usc.GetStoredCar(r.Context(), id)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		doc, err := json.Marshal(carDomainToNewDto(current))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		patched, err := applyPatch(r.Header.Get("Content-Type"), doc, body)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		car, err := decodePatchedCar(patched)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		c := newCarToDomain(car)
+		c.Id = id
+		c.Version = version
+		newCar, err := s.usc.UpdateCar(r.Context(), c)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := enc.marshal(carDomainToDto(newCar))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		setContentType(w, enc)
+		setETag(w, newCar)
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
 }
@@ -0,0 +1,201 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDeletedCar holds a single car that was deleted at version 2.
+type stubDeletedCar struct {
+	usecases
+	car entities.Car
+}
+
+func (u *stubDeletedCar) GetCarById(_ context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
+	if id != u.car.Id || u.car.DeletedAt != nil && !includeDeleted {
+		return entities.Car{}, entities.ErrNotFound
+	}
+
+	return u.car, nil
+}
+
+func (u *stubDeletedCar) RestoreCar(_ context.Context, id uuid.UUID, version int64) (entities.Car, error) {
+	switch {
+	case id != u.car.Id:
+		return entities.Car{}, entities.ErrNotFound
+	case u.car.DeletedAt == nil:
+		return entities.Car{}, fmt.Errorf("%w: car is not deleted", entities.ErrConflict)
+	case version != u.car.Version:
+		return entities.Car{}, entities.ErrVersionMismatch
+	}
+
+	u.car.DeletedAt = nil
+	u.car.Version++
+	return u.car, nil
+}
+
+// stubStaleCache serves an older version of the car unless it is read as
+// stored, as the cache of another instance would.
+type stubStaleCache struct {
+	stubDeletedCar
+	cached entities.Car
+}
+
+func (u *stubStaleCache) GetCarById(_ context.Context, _ uuid.UUID, _ bool) (entities.Car, error) {
+	return u.cached, nil
+}
+
+func (u *stubStaleCache) GetStoredCar(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+	return u.stubDeletedCar.GetCarById(ctx, id, false)
+}
+
+func (u *stubStaleCache) UpdateCar(_ context.Context, car entities.Car) (entities.Car, error) {
+	if car.Version != u.car.Version {
+		return entities.Car{}, entities.ErrVersionMismatch
+	}
+
+	car.Version++
+	return car, nil
+}
+
+func TestServer_PatchCar(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"editor": entities.RoleEditor})
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		deletedAt *time.Time
+		ifMatch   string
+		status    int
+	}{
+		{"patches stored car", nil, `"2"`, http.StatusOK},
+		{"with version of stale cache", nil, `"1"`, http.StatusPreconditionFailed},
+		{"car is deleted", &deletedAt, `"2"`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 12000, Version: 2, DeletedAt: tt.deletedAt}
+			cached := entities.Car{Id: car.Id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 1}
+			h := New(config.Service{}, &stubStaleCache{stubDeletedCar{car: car}, cached}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodPatch, "/cars/"+car.Id.String(), strings.NewReader(`{"color":"Blue"}`))
+			r.Header.Set(apiKeyHeader, "editor")
+			r.Header.Set("Content-Type", mergePatchContentType)
+			r.Header.Set("If-Match", tt.ifMatch)
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				dto := CarDto{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+				assert.Equal(t, "Blue", dto.Color)
+				assert.Equal(t, uint64(12000), dto.Cost)
+				assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestServer_GetDeletedCar(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"reader": entities.RoleReader, "admin": entities.RoleAdmin})
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 2, DeletedAt: &deletedAt}
+
+	tests := []struct {
+		name   string
+		key    string
+		query  string
+		status int
+	}{
+		{"hidden by default", "admin", "", http.StatusNotFound},
+		{"included for admins", "admin", "?include_deleted=true", http.StatusOK},
+		{"not included for readers", "reader", "?include_deleted=true", http.StatusForbidden},
+		{"invalid flag", "admin", "?include_deleted=maybe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			h := New(config.Service{}, &stubDeletedCar{car: car}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodGet, "/cars/"+car.Id.String()+tt.query, nil)
+			r.Header.Set(apiKeyHeader, tt.key)
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				dto := CarDto{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+				assert.Equal(t, &deletedAt, dto.DeletedAt)
+				assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestServer_RestoreCar(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin})
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		deletedAt *time.Time
+		ifMatch   string
+		status    int
+		code      string
+	}{
+		{"restores deleted car", &deletedAt, `"2"`, http.StatusOK, ""},
+		{"with stale version", &deletedAt, `"1"`, http.StatusPreconditionFailed, "version_mismatch"},
+		{"without if match", &deletedAt, "", http.StatusPreconditionRequired, "precondition_required"},
+		{"car is not deleted", nil, `"2"`, http.StatusConflict, "conflict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 2, DeletedAt: tt.deletedAt}
+			h := New(config.Service{}, &stubDeletedCar{car: car}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodPost, "/cars/"+car.Id.String()+"/restore", nil)
+			r.Header.Set(apiKeyHeader, "admin")
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				resp := problemResponse{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, tt.code, resp.Code)
+				return
+			}
+			dto := CarDto{}
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+			assert.Nil(t, dto.DeletedAt)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		})
+	}
+}
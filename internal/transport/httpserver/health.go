@@ -0,0 +1,97 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/health"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/gookit/slog"
+)
+
+// Messages of checks that are down. The probes are not authenticated, so
+// the errors of the checks are only logged.
+const (
+	checkTimedOut    = "check timed out"
+	checkUnavailable = "check failed"
+)
+
+type healthReportDto struct {
+	Status string                    `json:"status"`
+	Checks map[string]healthCheckDto `json:"checks,omitempty"`
+}
+
+type healthCheckDto struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// liveness godoc
+// @Summary      Liveness probe
+// @Description  Reports that the process is alive
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  healthReportDto
+// @Router       /healthz [get]
+func (s *Server) liveness() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, r, health.Report{Status: health.StatusUp})
+	}
+}
+
+// readiness godoc
+// @Summary      Readiness probe
+// @Description  Checks the database, the cache and the schema version
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  healthReportDto
+// @Failure      503  {object}  healthReportDto
+// @Router       /readyz [get]
+func (s *Server) readiness() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := time.Second * time.Duration(s.cfg.ReadinessTimeoutSeconds)
+		writeHealthReport(w, r, health.Run(r.Context(), timeout, s.readinessChecks))
+	}
+}
+
+func writeHealthReport(w http.ResponseWriter, r *http.Request, report health.Report) {
+	dto := healthReportDto{Status: string(report.Status)}
+	if len(report.Checks) > 0 {
+		dto.Checks = make(map[string]healthCheckDto, len(report.Checks))
+		for name, res := range report.Checks {
+			check := healthCheckDto{
+				Status:     string(res.Status),
+				DurationMs: float64(res.Duration.Microseconds()) / 1000,
+			}
+			if res.Err != nil {
+				check.Error = checkMessage(res.Err)
+				logger.FromContext(r.Context()).WithFields(slog.M{
+					"check": name,
+				}).Warn("readiness check failed: ", res.Err)
+			}
+			dto.Checks[name] = check
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto)
+}
+
+// checkMessage tells clients why a check is down without the error itself.
+func checkMessage(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return checkTimedOut
+	}
+
+	return checkUnavailable
+}
@@ -0,0 +1,61 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Readiness(t *testing.T) {
+	t.Run("hides errors of failed checks", func(t *testing.T) {
+		// Arrange
+		down := health.CheckerFunc(func(context.Context) error {
+			return errors.New(`dial tcp 10.0.0.5:5432: password authentication failed for user "postgres"`)
+		})
+		up := health.CheckerFunc(func(context.Context) error { return nil })
+		h := New(config.Service{ReadinessTimeoutSeconds: 1}, nil,
+			WithReadinessChecks(health.Check{Name: "database", Checker: down}, health.Check{Name: "cache", Checker: up}),
+		).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		dto := healthReportDto{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+		assert.Equal(t, checkUnavailable, dto.Checks["database"].Error)
+		assert.Empty(t, dto.Checks["cache"].Error)
+	})
+
+	t.Run("reports timed out checks", func(t *testing.T) {
+		// Arrange
+		hanging := health.CheckerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		h := New(config.Service{}, nil,
+			WithReadinessChecks(health.Check{Name: "database", Checker: hanging}),
+		).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		dto := healthReportDto{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+		assert.Equal(t, checkTimedOut, dto.Checks["database"].Error)
+	})
+}
@@ -0,0 +1,107 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// getCarHistory godoc
+// @Summary      Get the history of a car
+// @Description  Get every change of a car with who made it, oldest first. The history of deleted and purged cars is kept.
+// @Tags         cars
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Car ID"
+// @Success      200  {array}   CarRevisionDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/{id}/history [get]
+func (s *Server) getCarHistory() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		revisions, err := s.usc.GetCarHistory(r.Context(), id)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		dto := make([]CarRevisionDto, 0, len(revisions))
+		for _, rev := range revisions {
+			dto = append(dto, carRevisionDomainToDto(rev))
+		}
+
+		resp, err := json.Marshal(dto)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+// getCarRevisionDiff godoc
+// @Summary      Get the changes of a revision
+// @Description  Get the fields a revision of a car changed with their old and new values
+// @Tags         cars
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Car ID"
+// @Param        rev  path      int     true  "Revision"
+// @Success      200  {object}  CarRevisionDiffDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      404  {object}  problemResponse
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/{id}/history/{rev}/diff [get]
+func (s *Server) getCarRevisionDiff() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		revision, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 64)
+		if err != nil || revision < 1 {
+			newErrorResponse(w, r, badRequest(fmt.Errorf("invalid revision %q", chi.URLParam(r, "rev"))))
+			return
+		}
+
+		rev, err := s.usc.GetCarRevision(r.Context(), id, revision)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(carRevisionDiffDomainToDto(rev))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
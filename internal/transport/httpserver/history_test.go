@@ -0,0 +1,132 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/audit"
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHistory knows the history of a single car and remembers the origin
+// of the last request.
+type stubHistory struct {
+	usecases
+	revisions []entities.CarRevision
+	origin    audit.Origin
+}
+
+func (u *stubHistory) GetCarHistory(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error) {
+	u.origin = audit.FromContext(ctx)
+	if len(u.revisions) == 0 || id != u.revisions[0].CarId {
+		return nil, entities.ErrNotFound
+	}
+
+	return u.revisions, nil
+}
+
+func (u *stubHistory) GetCarRevision(_ context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error) {
+	for _, rev := range u.revisions {
+		if rev.CarId == id && rev.Revision == revision {
+			return rev, nil
+		}
+	}
+
+	return entities.CarRevision{}, entities.ErrNotFound
+}
+
+func TestServer_CarHistory(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"editor": entities.RoleEditor, "admin": entities.RoleAdmin})
+	id := uuid.New()
+	changedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	audi := entities.CarValues{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+	cheaper := audi
+	cheaper.Cost = 9500
+	revisions := []entities.CarRevision{
+		{CarId: id, Revision: 1, Operation: entities.RevisionInsert, New: &audi, Actor: "editor", RequestId: "req-1", ChangedAt: changedAt},
+		{CarId: id, Revision: 2, Operation: entities.RevisionUpdate, Old: &audi, New: &cheaper, Actor: "editor", RequestId: "req-2", ChangedAt: changedAt},
+	}
+
+	t.Run("history", func(t *testing.T) {
+		// Arrange
+		usc := &stubHistory{revisions: revisions}
+		h := New(config.Service{}, usc, keys).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/"+id.String()+"/history", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		r.Header.Set(middleware.RequestIDHeader, "req-3")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		dto := []CarRevisionDto{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&dto))
+		assert.Len(t, dto, 2)
+		assert.Equal(t, "insert", dto[0].Operation)
+		assert.Nil(t, dto[0].Old)
+		assert.Equal(t, &CarValuesDto{Brand: "Audi", Model: "A3", Color: "Red", Cost: 9500}, dto[1].New)
+		assert.Equal(t, "req-2", dto[1].RequestId)
+		assert.Equal(t, audit.Origin{Actor: "admin", RequestId: "req-3"}, usc.origin)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		// Arrange
+		h := New(config.Service{}, &stubHistory{revisions: revisions}, keys).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/"+id.String()+"/history/2/diff", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"revision": 2,
+			"operation": "update",
+			"actor": "editor",
+			"request_id": "req-2",
+			"changed_at": "2026-10-01T12:00:00Z",
+			"changes": [{"field": "cost", "old": 10000, "new": 9500}]
+		}`, w.Body.String())
+	})
+
+	tests := []struct {
+		name   string
+		key    string
+		path   string
+		status int
+	}{
+		{"history for editors", "editor", "/cars/" + id.String() + "/history", http.StatusForbidden},
+		{"history of unknown car", "admin", "/cars/" + uuid.NewString() + "/history", http.StatusNotFound},
+		{"history with invalid id", "admin", "/cars/42/history", http.StatusBadRequest},
+		{"diff of unknown revision", "admin", "/cars/" + id.String() + "/history/3/diff", http.StatusNotFound},
+		{"diff with invalid revision", "admin", "/cars/" + id.String() + "/history/0/diff", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			h := New(config.Service{}, &stubHistory{revisions: revisions}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set(apiKeyHeader, tt.key)
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
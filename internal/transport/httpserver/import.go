@@ -0,0 +1,174 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+)
+
+// maxImportRows bounds the rows of one import, they are all held in memory.
+const maxImportRows = 10000
+
+// importCodecs are the formats an import can be read from. Only their media
+// types are used, rows are read by decodeImport.
+var importCodecs = exportCodecs
+
+// importCars godoc
+// @Summary      Import cars
+// @Description  Import cars from NDJSON or CSV with a header row. Every row is validated, valid rows are stored unless mode is all-or-nothing and a row was rejected. Rows are numbered by their line in the file.
+// @Tags         cars
+// @Accept       application/x-ndjson,text/csv
+// @Produce      json
+// @Param        mode     query     string  false  "Import mode"  Enums(partial, all-or-nothing)  default(partial)
+// @Param        request  body      string  true   "Cars"
+// @Success      200  {object}  ImportReportDto
+// @Failure      400  {object}  problemResponse
+// @Failure      401  {object}  problemResponse
+// @Failure      403  {object}  problemResponse
+// @Failure      409  {object}  problemResponse
+// @Failure      415  {object}  problemResponse
+// @Failure      422  {object}  ImportReportDto
+// @Failure      429  {object}  problemResponse
+// @Failure      500  {object}  problemResponse
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /cars/import [post]
+func (s *Server) importCars() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := entities.ImportPartial
+		if v := r.URL.Query().Get("mode"); v != "" {
+			mode = entities.ImportMode(v)
+			if !mode.IsValid() {
+				newErrorResponse(w, r, badRequest(fmt.Errorf("invalid import mode %q", v)))
+				return
+			}
+		}
+
+		dec, err := requestCodec(r, importCodecs)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+		defer r.Body.Close()
+
+		rows, err := decodeImport(dec.mediaType, r.Body)
+		if err != nil {
+			newErrorResponse(w, r, badRequest(err))
+			return
+		}
+
+		report, err := s.usc.ImportCars(r.Context(), rows, mode)
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		resp, err := json.Marshal(importReportDomainToDto(report))
+		if err != nil {
+			newErrorResponse(w, r, err)
+			return
+		}
+
+		status := http.StatusOK
+		if report.RolledBack {
+			status = http.StatusUnprocessableEntity
+		}
+		w.WriteHeader(status)
+		w.Write(resp)
+	}
+}
+
+// decodeImport reads the cars of an import. A row that can not be read is
+// returned with its error, so it is reported instead of failing the import.
+// Only a body that can not be read at all is an error.
+func decodeImport(mediaType string, body io.Reader) ([]entities.ImportRow, error) {
+	if mediaType == csvMediaType {
+		return decodeCSVImport(body)
+	}
+
+	return decodeNDJSONImport(body)
+}
+
+func decodeNDJSONImport(body io.Reader) ([]entities.ImportRow, error) {
+	rows := []entities.ImportRow{}
+	scanner := bufio.NewScanner(body)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("an import can have at most %d rows", maxImportRows)
+		}
+
+		car := NewCarDto{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		row := entities.ImportRow{Row: line}
+		if err := dec.Decode(&car); err != nil {
+			row.Err = fmt.Errorf("invalid JSON: %s", err)
+		} else {
+			row.Car = newCarToDomain(car)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func decodeCSVImport(body io.Reader) ([]entities.ImportRow, error) {
+	reader := csv.NewReader(body)
+	// Rows with a wrong number of fields are reported per row.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("header row is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []entities.ImportRow{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("an import can have at most %d rows", maxImportRows)
+		}
+
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rows = append(rows, entities.ImportRow{Row: parseErr.StartLine, Err: fmt.Errorf("invalid CSV: %s", parseErr.Err)})
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := entities.ImportRow{Row: line}
+		if len(record) != len(header) {
+			row.Err = fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+		} else if car, err := newCarFromCSV(csvFields(header, record)); err != nil {
+			row.Err = err
+		} else {
+			row.Car = newCarToDomain(car)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,190 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubImport rejects rows that could not be decoded and accepts the rest.
+type stubImport struct {
+	usecases
+	rows []entities.ImportRow
+	mode entities.ImportMode
+}
+
+func (u *stubImport) ImportCars(_ context.Context, rows []entities.ImportRow, mode entities.ImportMode) (entities.ImportReport, error) {
+	u.rows, u.mode = rows, mode
+	report := entities.ImportReport{}
+	for _, row := range rows {
+		if row.Err != nil {
+			report.Rejected = append(report.Rejected, entities.RejectedRow{Row: row.Row, Reason: row.Err.Error()})
+		}
+	}
+	if mode == entities.ImportAllOrNothing && len(report.Rejected) > 0 {
+		report.RolledBack = true
+	} else {
+		report.Accepted = len(rows) - len(report.Rejected)
+	}
+
+	return report, nil
+}
+
+func TestDecodeImport(t *testing.T) {
+	audi := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+
+	tests := []struct {
+		name      string
+		mediaType string
+		body      string
+		rows      []entities.ImportRow
+		errs      map[int]string
+		err       bool
+	}{
+		{
+			name:      "ndjson",
+			mediaType: ndjsonMediaType,
+			body: `{"brand":"Audi","model":"A3","color":"Red","cost":10000}` + "\n\n" +
+				`{"brand":"Audi","id":"1"}` + "\n" +
+				`{"brand":` + "\n",
+			rows: []entities.ImportRow{{Row: 1, Car: audi}, {Row: 3}, {Row: 4}},
+			errs: map[int]string{3: `invalid JSON: json: unknown field "id"`, 4: "invalid JSON: unexpected EOF"},
+		},
+		{
+			name:      "csv",
+			mediaType: csvMediaType,
+			body:      "cost,brand,model,color\n10000,Audi,A3,Red\n1,Audi\ncheap,Audi,A3,Red\n",
+			rows:      []entities.ImportRow{{Row: 2, Car: audi}, {Row: 3}, {Row: 4}},
+			errs:      map[int]string{3: "expected 4 fields, got 2", 4: `invalid cost "cheap"`},
+		},
+		{
+			name:      "csv with broken quotes",
+			mediaType: csvMediaType,
+			body:      "brand,model,color,cost\nAu\"di,A3,Red,10000\nAudi,A3,Red,10000\n",
+			rows:      []entities.ImportRow{{Row: 2}, {Row: 3, Car: audi}},
+			errs:      map[int]string{2: `invalid CSV: bare " in non-quoted-field`},
+		},
+		{
+			name:      "csv without header",
+			mediaType: csvMediaType,
+			body:      "",
+			err:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			rows, err := decodeImport(tt.mediaType, strings.NewReader(tt.body))
+
+			// Assert
+			assert.Equal(t, tt.err, err != nil)
+			assert.Len(t, rows, len(tt.rows))
+			for i, row := range rows {
+				assert.Equal(t, tt.rows[i].Row, row.Row)
+				if msg, ok := tt.errs[row.Row]; ok {
+					assert.EqualError(t, row.Err, msg)
+					continue
+				}
+				assert.NoError(t, row.Err)
+				assert.Equal(t, tt.rows[i].Car, row.Car)
+			}
+		})
+	}
+}
+
+func TestServer_ImportCars(t *testing.T) {
+	keys := WithAPIKeys(stubAPIKeys{"editor": entities.RoleEditor})
+	body := "brand,model,color,cost\nAudi,A3,Red,10000\nAudi,A4\n"
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		status      int
+		report      ImportReportDto
+		code        string
+	}{
+		{
+			name:        "partial",
+			contentType: "text/csv",
+			status:      http.StatusOK,
+			report: ImportReportDto{
+				Accepted: 1,
+				Rejected: []RejectedRowDto{{Row: 3, Reason: "expected 4 fields, got 2"}},
+			},
+		},
+		{
+			name:        "all or nothing",
+			query:       "?mode=all-or-nothing",
+			contentType: "text/csv; charset=utf-8",
+			status:      http.StatusUnprocessableEntity,
+			report: ImportReportDto{
+				Rejected:   []RejectedRowDto{{Row: 3, Reason: "expected 4 fields, got 2"}},
+				RolledBack: true,
+			},
+		},
+		{
+			name:        "unknown mode",
+			query:       "?mode=some",
+			contentType: "text/csv",
+			status:      http.StatusBadRequest,
+			code:        "bad_request",
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			status:      http.StatusUnsupportedMediaType,
+			code:        "unsupported_media_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			h := New(config.Service{}, &stubImport{}, keys).addHandlers()
+			r := httptest.NewRequest(http.MethodPost, "/cars/import"+tt.query, strings.NewReader(body))
+			r.Header.Set(apiKeyHeader, "editor")
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			// Act
+			h.ServeHTTP(w, r)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				resp := problemResponse{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, tt.code, resp.Code)
+				return
+			}
+			report := ImportReportDto{}
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+			assert.Equal(t, tt.report, report)
+		})
+	}
+}
+
+func TestServer_ImportCars_TooManyRows(t *testing.T) {
+	// Arrange
+	h := New(config.Service{}, &stubImport{}, WithAPIKeys(stubAPIKeys{"editor": entities.RoleEditor})).addHandlers()
+	body := strings.Repeat(`{"brand":"Audi","model":"A3","color":"Red","cost":10000}`+"\n", maxImportRows+1)
+	r := httptest.NewRequest(http.MethodPost, "/cars/import", strings.NewReader(body))
+	r.Header.Set(apiKeyHeader, "editor")
+	r.Header.Set("Content-Type", ndjsonMediaType)
+	w := httptest.NewRecorder()
+
+	// Act
+	h.ServeHTTP(w, r)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,14 +1,89 @@
 package httpserver
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/gookit/slog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// untracedPaths are polled by probes and scrapers and would only add noise.
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// tracer starts a server span per request, continuing the trace from the
+// W3C traceparent header of the request if there is one.
+func tracer() func(next http.Handler) http.Handler {
+	return otelhttp.NewMiddleware("http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+	)
+}
+
+// traceRoute names the server span after the chi route pattern once the
+// request has been routed, e.g. "GET /cars/{id}/".
+func traceRoute(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + rctx.RoutePattern())
+		span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// exposedHeaders are the response headers browsers let scripts read.
+var exposedHeaders = []string{
+	"ETag",
+	"Link",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+}
+
+// corsPolicy answers preflight requests and adds the CORS headers to
+// responses for the allowed origins. It runs before authentication because
+// browsers send preflight requests without credentials.
+func corsPolicy(cfg config.CORS) func(next http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAgeSeconds),
+	})
+}
+
 func setResponseHeader() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			switch r.Method {
-			case http.MethodGet, http.MethodPost, http.MethodPut:
+			case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
 				w.Header().Add("Content-type", "application/json")
 			}
 
@@ -17,3 +92,165 @@ func setResponseHeader() func(next http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// requestLogger puts a logger with the request id and trace id into the
+// request context and writes one access log line per request. Requests whose
+// handler panics, e.g. with http.ErrAbortHandler to abort a response that
+// failed after the status was sent, are logged as aborted while the panic
+// unwinds. It expects middleware.RequestID and tracer to run before it.
+func requestLogger(l *slog.SugaredLogger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			fields := slog.M{
+				"request_id": middleware.GetReqID(r.Context()),
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				fields["trace_id"] = sc.TraceID().String()
+			}
+			rec := l.WithFields(fields)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			completed := false
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				route := ""
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					route = rctx.RoutePattern()
+				}
+
+				access := rec.Copy().WithFields(slog.M{
+					"method":      r.Method,
+					"path":        r.URL.Path,
+					"route":       route,
+					"status":      status,
+					"bytes":       ww.BytesWritten(),
+					"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+					"client_ip":   clientIP(r),
+				})
+				if !completed {
+					access.WithField("aborted", true).Warn("request aborted")
+					return
+				}
+				access.Info("request completed")
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(logger.WithRecord(r.Context(), rec)))
+			completed = true
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// forwardedClient replaces the remote address of requests from trusted
+// proxies with the client address they forwarded. Headers of requests from
+// anywhere else are ignored, any client can set them.
+func forwardedClient(proxies []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(proxies) == 0 {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIP(r, proxies); ip != nil {
+				r.RemoteAddr = ip.String()
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// forwardedIP returns the client address of a request from a trusted proxy,
+// or nil. Every proxy appends the address it got the request from, so the
+// addresses are walked from the last one and the first address that is not
+// a trusted proxy is the client. Forwarded is preferred to X-Forwarded-For.
+func forwardedIP(r *http.Request, proxies []*net.IPNet) net.IP {
+	if !trusted(net.ParseIP(clientIP(r)), proxies) {
+		return nil
+	}
+
+	hops := forwardedHops(r.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		hops = forwardedForHops(r.Header.Values("X-Forwarded-For"))
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// An obfuscated or malformed address, the client is unknown.
+			return nil
+		}
+		if i == 0 || !trusted(ip, proxies) {
+			return ip
+		}
+	}
+
+	return nil
+}
+
+// forwardedHops returns the for parameters of Forwarded headers (RFC 7239),
+// e.g. `for=192.0.2.60;proto=https, for="[2001:db8::17]:4711"`.
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hops = append(hops, stripPort(strings.Trim(value, `"`)))
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// forwardedForHops returns the addresses of X-Forwarded-For headers.
+func forwardedForHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, stripPort(strings.TrimSpace(hop)))
+		}
+	}
+
+	return hops
+}
+
+// stripPort removes the port from an address and the brackets from an IPv6
+// address.
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+func trusted(ip net.IP, proxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
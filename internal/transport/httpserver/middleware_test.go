@@ -0,0 +1,217 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/gookit/slog"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestLogger(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := slog.NewJSONSugared(buf, slog.InfoLevel)
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger(l))
+	r.Get("/cars/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("handling")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("{}"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/cars/42", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+
+	// Act
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Assert
+	dec := json.NewDecoder(buf)
+	handlerLine, accessLine := map[string]interface{}{}, map[string]interface{}{}
+	assert.NoError(t, dec.Decode(&handlerLine))
+	assert.NoError(t, dec.Decode(&accessLine))
+
+	assert.Equal(t, "handling", handlerLine["message"])
+	assert.Equal(t, "req-1", handlerLine["request_id"])
+
+	assert.Equal(t, "request completed", accessLine["message"])
+	assert.Equal(t, "req-1", accessLine["request_id"])
+	assert.Equal(t, "GET", accessLine["method"])
+	assert.Equal(t, "/cars/{id}", accessLine["route"])
+	assert.Equal(t, float64(http.StatusNotFound), accessLine["status"])
+	assert.Equal(t, float64(2), accessLine["bytes"])
+	assert.Equal(t, "192.0.2.1", accessLine["client_ip"])
+	assert.Contains(t, accessLine, "duration_ms")
+}
+
+func TestRequestLogger_Aborted(t *testing.T) {
+	// Arrange
+	buf := &bytes.Buffer{}
+	l := slog.NewJSONSugared(buf, slog.InfoLevel)
+	r := chi.NewRouter()
+	r.Use(requestLogger(l))
+	r.Get("/cars/export", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic(http.ErrAbortHandler)
+	})
+
+	// Act & Assert
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cars/export", nil))
+	})
+	accessLine := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(buf).Decode(&accessLine))
+	assert.Equal(t, "request aborted", accessLine["message"])
+	assert.Equal(t, "WARNING", accessLine["level"])
+	assert.Equal(t, true, accessLine["aborted"])
+	assert.Equal(t, float64(http.StatusOK), accessLine["status"])
+	assert.Equal(t, "/cars/export", accessLine["route"])
+}
+
+func TestTracer(t *testing.T) {
+	// Arrange
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var handlerTraceId trace.TraceID
+	r := chi.NewRouter()
+	r.Use(tracer(), traceRoute)
+	r.Get("/cars/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTraceId = trace.SpanContextFromContext(r.Context()).TraceID()
+	})
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodGet, "/cars/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// Act
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Assert
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /cars/{id}", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, spans[0].SpanContext().TraceID(), handlerTraceId)
+}
+
+func TestForwardedClient(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwarded     string
+		xForwardedFor string
+		clientIP      string
+	}{
+		{"headers of client are ignored", "192.0.2.1:1234", "", "203.0.113.7", "192.0.2.1"},
+		{"x-forwarded-for of proxy", "10.0.0.1:1234", "", "203.0.113.7", "203.0.113.7"},
+		{"address prepended by client", "10.0.0.1:1234", "", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"chain of proxies", "10.0.0.1:1234", "", "203.0.113.7, 10.0.0.2", "203.0.113.7"},
+		{"forwarded of proxy", "10.0.0.1:1234", `for="[2001:db8::17]:4711";proto=https, for=10.0.0.2`, "", "2001:db8::17"},
+		{"forwarded is preferred", "10.0.0.1:1234", "for=198.51.100.1", "203.0.113.7", "198.51.100.1"},
+		{"obfuscated address", "10.0.0.1:1234", "for=_hidden", "", "10.0.0.1"},
+		{"only proxies", "10.0.0.1:1234", "", "10.0.0.3", "10.0.0.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var got string
+			h := forwardedClient([]*net.IPNet{proxies})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/cars/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("Forwarded", tt.forwarded)
+			}
+			if tt.xForwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+			}
+
+			// Act
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			// Assert
+			assert.Equal(t, tt.clientIP, got)
+		})
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	cfg := config.Service{CORS: config.CORS{
+		AllowedOrigins: []string{"https://*.dealers.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+		MaxAgeSeconds:  600,
+	}}
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		// Arrange
+		h := New(cfg, nil).addHandlers()
+		r := httptest.NewRequest(http.MethodOptions, "/cars/"+uuid.NewString(), nil)
+		r.Header.Set("Origin", "https://north.dealers.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		r.Header.Set("Access-Control-Request-Headers", "X-API-Key")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://north.dealers.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, http.MethodPut, w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "X-Api-Key", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("preflight from other origin", func(t *testing.T) {
+		// Arrange
+		h := New(cfg, nil).addHandlers()
+		r := httptest.NewRequest(http.MethodOptions, "/cars/", nil)
+		r.Header.Set("Origin", "https://evil.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("actual request exposes headers", func(t *testing.T) {
+		// Arrange
+		h := New(cfg, nil).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/", nil)
+		r.Header.Set("Origin", "https://north.dealers.example.com")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "https://north.dealers.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Etag")
+	})
+}
@@ -1,18 +1,139 @@
 package httpserver
 
-import "github.com/google/uuid"
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Car DTOs are also encoded as XML, YAML and MessagePack, the latter with
+// the JSON field names.
 
 type NewCarDto struct {
-	Brand string `json:"brand"`
-	Model string `json:"model"`
-	Color string `json:"color"`
-	Cost  uint64 `json:"cost"`
+	XMLName xml.Name `json:"-" xml:"car" yaml:"-" swaggerignore:"true"`
+	Brand   string   `json:"brand" xml:"brand" yaml:"brand"`
+	Model   string   `json:"model" xml:"model" yaml:"model"`
+	Color   string   `json:"color" xml:"color" yaml:"color"`
+	Cost    uint64   `json:"cost" xml:"cost" yaml:"cost"`
 }
 
 type CarDto struct {
-	Id    uuid.UUID `json:"id"`
-	Brand string    `json:"brand"`
-	Model string    `json:"model"`
-	Color string    `json:"color"`
-	Cost  uint64    `json:"cost"`
+	XMLName xml.Name  `json:"-" xml:"car" yaml:"-" swaggerignore:"true"`
+	Id      uuid.UUID `json:"id" xml:"id" yaml:"id"`
+	Brand   string    `json:"brand" xml:"brand" yaml:"brand"`
+	Model   string    `json:"model" xml:"model" yaml:"model"`
+	Color   string    `json:"color" xml:"color" yaml:"color"`
+	Cost    uint64    `json:"cost" xml:"cost" yaml:"cost"`
+	// DeletedAt is only set on deleted cars, which only admins can see.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+}
+
+type CarsPageDto struct {
+	XMLName    xml.Name `json:"-" xml:"cars" yaml:"-" swaggerignore:"true"`
+	Cars       []CarDto `json:"cars" xml:"car" yaml:"cars"`
+	NextCursor string   `json:"next_cursor,omitempty" xml:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+type BatchRequestDto struct {
+	Operations []CarOperationDto `json:"operations"`
+}
+
+// CarOperationDto is one operation of a batch. Updates and deletes need the
+// id and the ETag of the car, creates and updates the new values of the car.
+type CarOperationDto struct {
+	Op      string     `json:"op" enums:"create,update,delete"`
+	Id      *uuid.UUID `json:"id,omitempty"`
+	IfMatch string     `json:"if_match,omitempty"`
+	Car     *NewCarDto `json:"car,omitempty"`
+}
+
+// BatchResponseDto has a result per operation. In a batch that was not
+// committed the failed operation carries the error, the others have status
+// 424.
+type BatchResponseDto struct {
+	Committed bool                 `json:"committed"`
+	Results   []OperationResultDto `json:"results"`
+}
+
+type OperationResultDto struct {
+	Op     string           `json:"op"`
+	Status int              `json:"status"`
+	ETag   string           `json:"etag,omitempty"`
+	Car    *CarDto          `json:"car,omitempty"`
+	Error  *problemResponse `json:"error,omitempty"`
+}
+
+// ImportReportDto lists the rejected rows of an import by their line in the
+// imported file. RolledBack is set when an all-or-nothing import stored
+// nothing.
+type ImportReportDto struct {
+	Accepted   int              `json:"accepted"`
+	Rejected   []RejectedRowDto `json:"rejected"`
+	RolledBack bool             `json:"rolled_back"`
+}
+
+type RejectedRowDto struct {
+	Row    int                  `json:"row"`
+	Reason string               `json:"reason"`
+	Errors []fieldErrorResponse `json:"errors,omitempty"`
+}
+
+type NewAPIKeyDto struct {
+	Name string `json:"name"`
+	Role string `json:"role" enums:"reader,editor,admin"`
+}
+
+type APIKeyDto struct {
+	Id        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Role      string     `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKeyDto carries the secret of a new key. It is returned once and
+// can not be read again.
+type CreatedAPIKeyDto struct {
+	APIKeyDto
+	Key string `json:"key"`
+}
+
+// CarRevisionDto is one change of a car. Old is missing for an insert and
+// New for a purge, Actor and RequestId for changes made by the service.
+type CarRevisionDto struct {
+	Revision  int64         `json:"revision"`
+	Operation string        `json:"operation" enums:"insert,update,delete,restore,purge"`
+	Old       *CarValuesDto `json:"old,omitempty"`
+	New       *CarValuesDto `json:"new,omitempty"`
+	Actor     string        `json:"actor,omitempty"`
+	RequestId string        `json:"request_id,omitempty"`
+	ChangedAt time.Time     `json:"changed_at"`
+}
+
+type CarValuesDto struct {
+	Brand     string     `json:"brand"`
+	Model     string     `json:"model"`
+	Color     string     `json:"color"`
+	Cost      uint64     `json:"cost"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// CarRevisionDiffDto lists the fields a revision changed.
+type CarRevisionDiffDto struct {
+	Revision  int64            `json:"revision"`
+	Operation string           `json:"operation" enums:"insert,update,delete,restore,purge"`
+	Actor     string           `json:"actor,omitempty"`
+	RequestId string           `json:"request_id,omitempty"`
+	ChangedAt time.Time        `json:"changed_at"`
+	Changes   []FieldChangeDto `json:"changes"`
+}
+
+// FieldChangeDto is the old and the new value of a field, null where the
+// car did not exist or was not deleted.
+type FieldChangeDto struct {
+	Field string      `json:"field" enums:"brand,model,color,cost,deleted_at"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
 }
@@ -0,0 +1,69 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedMediaType = errors.New("unsupported media type")
+	// errPatchConflict is returned when a well-formed patch can not be
+	// applied to the current state of a car, e.g. a failed "test" operation.
+	errPatchConflict = errors.New("patch can not be applied")
+)
+
+// applyPatch applies a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902) to doc, depending on the content type of the patch.
+func applyPatch(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedMediaType, contentType)
+	}
+
+	switch mediaType {
+	case mergePatchContentType:
+		if !json.Valid(patch) {
+			return nil, badRequest(errors.New("merge patch is not valid JSON"))
+		}
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, badRequest(fmt.Errorf("invalid merge patch: %s", err))
+		}
+		return patched, nil
+	case jsonPatchContentType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, badRequest(fmt.Errorf("invalid JSON patch: %s", err))
+		}
+		patched, err := ops.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errPatchConflict, err)
+		}
+		return patched, nil
+	default:
+		return nil, fmt.Errorf("%w: %q, use %s or %s", errUnsupportedMediaType, mediaType, mergePatchContentType, jsonPatchContentType)
+	}
+}
+
+// decodePatchedCar decodes a patched document and rejects fields a car does
+// not have, so a patch can not silently touch e.g. the id.
+func decodePatchedCar(doc []byte) (NewCarDto, error) {
+	car := NewCarDto{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&car); err != nil {
+		return NewCarDto{}, badRequest(fmt.Errorf("patched car: %s", err))
+	}
+
+	return car, nil
+}
@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPatch(t *testing.T) {
+	doc := []byte(`{"brand":"Audi","model":"A3","color":"Red","cost":10000}`)
+
+	t.Run("merge patch", func(t *testing.T) {
+		// Act
+		patched, err := applyPatch("application/merge-patch+json; charset=utf-8", doc, []byte(`{"color":"Green"}`))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"brand":"Audi","model":"A3","color":"Green","cost":10000}`, string(patched))
+	})
+
+	t.Run("json patch", func(t *testing.T) {
+		// Arrange
+		patch := []byte(`[{"op":"test","path":"/cost","value":10000},{"op":"replace","path":"/cost","value":12000}]`)
+
+		// Act
+		patched, err := applyPatch(jsonPatchContentType, doc, patch)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"brand":"Audi","model":"A3","color":"Red","cost":12000}`, string(patched))
+	})
+
+	t.Run("json patch with failed test", func(t *testing.T) {
+		// Arrange
+		patch := []byte(`[{"op":"test","path":"/cost","value":1},{"op":"replace","path":"/cost","value":12000}]`)
+
+		// Act
+		_, err := applyPatch(jsonPatchContentType, doc, patch)
+
+		// Assert
+		assert.ErrorIs(t, err, errPatchConflict)
+	})
+
+	t.Run("malformed json patch", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(jsonPatchContentType, doc, []byte(`{"op":"replace"}`))
+
+		// Assert
+		assert.ErrorIs(t, err, errBadRequest)
+	})
+
+	t.Run("malformed merge patch", func(t *testing.T) {
+		// Act
+		_, err := applyPatch(mergePatchContentType, doc, []byte(`{"color":`))
+
+		// Assert
+		assert.ErrorIs(t, err, errBadRequest)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		// Act
+		_, err := applyPatch("application/json", doc, []byte(`{"color":"Green"}`))
+
+		// Assert
+		assert.ErrorIs(t, err, errUnsupportedMediaType)
+	})
+}
+
+func TestDecodePatchedCar(t *testing.T) {
+	t.Run("car fields", func(t *testing.T) {
+		// Act
+		car, err := decodePatchedCar([]byte(`{"brand":"Audi","model":"A3","color":"Green","cost":10000}`))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, NewCarDto{Brand: "Audi", Model: "A3", Color: "Green", Cost: 10000}, car)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		// Act
+		_, err := decodePatchedCar([]byte(`{"id":"bea1b24d-0627-4ea0-aa2b-8af4c6c2a41c","brand":"Audi"}`))
+
+		// Assert
+		assert.ErrorIs(t, err, errBadRequest)
+	})
+}
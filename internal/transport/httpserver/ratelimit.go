@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/logger"
+	"gihub.com/gibiw/api-example/internal/ratelimit"
+)
+
+// Route groups limits are configured for. The ip group limits every
+// client before authentication.
+const (
+	rateLimitRead  = "read"
+	rateLimitWrite = "write"
+	rateLimitAdmin = "admin"
+	rateLimitIP    = "ip"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+type rateLimiter interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error)
+}
+
+// rateLimit takes a token from the bucket of the principal for the route
+// group and rejects the request if the bucket is empty.
+func (s *Server) rateLimit(group string) func(next http.Handler) http.Handler {
+	return s.limitBy(group, func(r *http.Request) string {
+		p, _ := principalFromContext(r.Context())
+		return group + ":principal:" + p.Id
+	})
+}
+
+// rateLimitClient takes a token from the bucket of the client IP. It runs
+// before authentication, so clients without valid credentials are limited
+// too and can not make us look up keys at will.
+func (s *Server) rateLimitClient() func(next http.Handler) http.Handler {
+	return s.limitBy(rateLimitIP, func(r *http.Request) string {
+		return rateLimitIP + ":" + clientIP(r)
+	})
+}
+
+// limitBy rejects the request if the bucket under the key of the request is
+// empty. If the store fails the request is let through rather than failing
+// the API.
+func (s *Server) limitBy(group string, key func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limit, ok := s.rateLimits[group]
+		if s.limiter == nil || !ok {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			res, err := s.limiter.Take(r.Context(), key(r), limit)
+			if err != nil {
+				logger.FromContext(r.Context()).Error("can not check rate limit: ", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+			w.Header().Set("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+				newErrorResponse(w, r, errRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
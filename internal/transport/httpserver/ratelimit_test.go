@@ -0,0 +1,157 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubLimiter records the keys it was asked for and answers with a fixed result.
+type stubLimiter struct {
+	res  ratelimit.Result
+	err  error
+	keys []string
+}
+
+func (l *stubLimiter) Take(_ context.Context, key string, _ ratelimit.Limit) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	return l.res, l.err
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	limits := map[string]ratelimit.Limit{rateLimitAdmin: {Rate: 1, Burst: 5}}
+
+	t.Run("allowed", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{res: ratelimit.Result{Allowed: true, Limit: 5, Remaining: 4, Reset: 1500 * time.Millisecond}}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"admin:principal:admin"}, l.keys)
+		assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "4", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{res: ratelimit.Result{Limit: 5, Reset: 5 * time.Second, RetryAfter: 300 * time.Millisecond}}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+		resp := problemResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "rate_limited", resp.Code)
+	})
+
+	t.Run("store fails", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{err: errors.New("connection refused")}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("forbidden request is not counted", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"reader": entities.RoleReader}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "reader")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, l.keys)
+	})
+
+	t.Run("client is limited by ip before authentication", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{res: ratelimit.Result{Limit: 40, Reset: time.Second, RetryAfter: 50 * time.Millisecond}}
+		limits := map[string]ratelimit.Limit{rateLimitIP: {Rate: 20, Burst: 40}, rateLimitAdmin: {Rate: 1, Burst: 5}}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "unknown")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, []string{"ip:192.0.2.1"}, l.keys)
+	})
+
+	t.Run("client behind trusted proxy is limited by forwarded ip", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{res: ratelimit.Result{Limit: 40, Reset: time.Second, RetryAfter: 50 * time.Millisecond}}
+		limits := map[string]ratelimit.Limit{rateLimitIP: {Rate: 20, Burst: 40}}
+		_, proxies, _ := net.ParseCIDR("192.0.2.0/24")
+		h := New(config.Service{}, nil, WithRateLimits(l, limits), WithTrustedProxies(proxies)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/cars/", nil)
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, []string{"ip:203.0.113.7"}, l.keys)
+	})
+
+	t.Run("authenticated client is limited by ip and principal", func(t *testing.T) {
+		// Arrange
+		l := &stubLimiter{res: ratelimit.Result{Allowed: true, Limit: 5, Remaining: 4, Reset: time.Second}}
+		limits := map[string]ratelimit.Limit{rateLimitIP: {Rate: 20, Burst: 40}, rateLimitAdmin: {Rate: 1, Burst: 5}}
+		h := New(config.Service{}, nil, WithAPIKeys(stubAPIKeys{"admin": entities.RoleAdmin}), WithRateLimits(l, limits)).addHandlers()
+		r := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		r.Header.Set(apiKeyHeader, "admin")
+		w := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(w, r)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"ip:192.0.2.1", "admin:principal:admin"}, l.keys)
+	})
+}
@@ -2,16 +2,135 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gookit/slog"
 )
 
-type errorResponse struct {
+const problemContentType = "application/problem+json"
+
+// errBadRequest marks errors caused by a request the server can not parse.
+var errBadRequest = errors.New("bad request")
+
+// problemResponse is an RFC 7807 problem details object. Code is a stable
+// machine-readable identifier of the problem type.
+type problemResponse struct {
+	Type          string               `json:"type"`
+	Title         string               `json:"title"`
+	Status        int                  `json:"status"`
+	Detail        string               `json:"detail,omitempty"`
+	Instance      string               `json:"instance,omitempty"`
+	Code          string               `json:"code"`
+	CorrelationId string               `json:"correlation_id,omitempty"`
+	Errors        []fieldErrorResponse `json:"errors,omitempty"`
+}
+
+type fieldErrorResponse struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
 }
 
-func newErrorResponse(w http.ResponseWriter, status int, err error) {
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(errorResponse{
-		Message: err.Error(),
-	})
+type problemType struct {
+	code   string
+	title  string
+	status int
+}
+
+var (
+	problemBadRequest           = problemType{"bad_request", "Bad request", http.StatusBadRequest}
+	problemNotFound             = problemType{"not_found", "Resource not found", http.StatusNotFound}
+	problemConflict             = problemType{"conflict", "Conflict with the current state of the resource", http.StatusConflict}
+	problemValidation           = problemType{"validation_failed", "Validation failed", http.StatusUnprocessableEntity}
+	problemVersionMismatch      = problemType{"version_mismatch", "Resource has been changed", http.StatusPreconditionFailed}
+	problemPreconditionRequired = problemType{"precondition_required", "If-Match header is required", http.StatusPreconditionRequired}
+	problemUnsupportedMediaType = problemType{"unsupported_media_type", "Unsupported media type", http.StatusUnsupportedMediaType}
+	problemNotAcceptable        = problemType{"not_acceptable", "Requested media type can not be produced", http.StatusNotAcceptable}
+	problemPatchConflict        = problemType{"patch_conflict", "Patch can not be applied", http.StatusConflict}
+	problemUnauthenticated      = problemType{"unauthenticated", "Authentication required", http.StatusUnauthorized}
+	problemForbidden            = problemType{"forbidden", "Permission denied", http.StatusForbidden}
+	problemRateLimited          = problemType{"rate_limited", "Too many requests", http.StatusTooManyRequests}
+	problemInternal             = problemType{"internal_error", "Internal server error", http.StatusInternalServerError}
+)
+
+func badRequest(err error) error {
+	return fmt.Errorf("%w: %s", errBadRequest, err)
+}
+
+func newErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	resp := newProblem(r, err)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newProblem describes err as a problem of the request.
+func newProblem(r *http.Request, err error) problemResponse {
+	p := problemFor(err)
+	resp := problemResponse{
+		Type:          "/problems/" + p.code,
+		Title:         p.title,
+		Status:        p.status,
+		Detail:        err.Error(),
+		Instance:      r.URL.Path,
+		Code:          p.code,
+		CorrelationId: middleware.GetReqID(r.Context()),
+	}
+
+	var validationErr *entities.ValidationError
+	if errors.As(err, &validationErr) {
+		resp.Detail = ""
+		for _, f := range validationErr.Fields {
+			resp.Errors = append(resp.Errors, fieldErrorResponse{Field: f.Field, Message: f.Message})
+		}
+	}
+
+	// Internal errors may carry driver messages and must not reach clients,
+	// they are logged under the correlation id instead.
+	if p == problemInternal {
+		resp.Detail = ""
+		logger.FromContext(r.Context()).WithFields(slog.M{
+			"correlation_id": resp.CorrelationId,
+			"method":         r.Method,
+			"path":           r.URL.Path,
+		}).Error("request failed: ", err)
+	}
+
+	return resp
+}
+
+func problemFor(err error) problemType {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return problemBadRequest
+	case errors.Is(err, errPreconditionRequired):
+		return problemPreconditionRequired
+	case errors.Is(err, errUnsupportedMediaType):
+		return problemUnsupportedMediaType
+	case errors.Is(err, errNotAcceptable):
+		return problemNotAcceptable
+	case errors.Is(err, errPatchConflict):
+		return problemPatchConflict
+	case errors.Is(err, entities.ErrUnauthenticated):
+		return problemUnauthenticated
+	case errors.Is(err, entities.ErrForbidden):
+		return problemForbidden
+	case errors.Is(err, errRateLimited):
+		return problemRateLimited
+	case errors.Is(err, entities.ErrNotFound):
+		return problemNotFound
+	case errors.Is(err, entities.ErrConflict):
+		return problemConflict
+	case errors.Is(err, entities.ErrValidation):
+		return problemValidation
+	case errors.Is(err, entities.ErrVersionMismatch):
+		return problemVersionMismatch
+	default:
+		return problemInternal
+	}
 }
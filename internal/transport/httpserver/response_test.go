@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewErrorResponse(t *testing.T) {
+	t.Run("domain error", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/cars/42", nil)
+		err := fmt.Errorf("car with id 42: %w", entities.ErrNotFound)
+
+		// Act
+		newErrorResponse(w, r, err)
+
+		// Assert
+		resp := problemResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+		assert.Equal(t, problemResponse{
+			Type:     "/problems/not_found",
+			Title:    "Resource not found",
+			Status:   http.StatusNotFound,
+			Detail:   "car with id 42: not found",
+			Instance: "/cars/42",
+			Code:     "not_found",
+		}, resp)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/cars", nil)
+		err := &entities.ValidationError{Fields: []entities.FieldError{{Field: "brand", Message: "is required"}}}
+
+		// Act
+		newErrorResponse(w, r, err)
+
+		// Assert
+		resp := problemResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "validation_failed", resp.Code)
+		assert.Equal(t, []fieldErrorResponse{{Field: "brand", Message: "is required"}}, resp.Errors)
+	})
+
+	t.Run("internal error is hidden", func(t *testing.T) {
+		// Arrange
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/cars", nil)
+		err := errors.New(`pq: relation "cars" does not exist`)
+
+		// Act
+		newErrorResponse(w, r, err)
+
+		// Assert
+		resp := problemResponse{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "internal_error", resp.Code)
+		assert.Empty(t, resp.Detail)
+		assert.NotContains(t, w.Body.String(), "pq:")
+	})
+}
@@ -2,75 +2,225 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"gihub.com/gibiw/api-example/internal/config"
 	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/health"
+	"gihub.com/gibiw/api-example/internal/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
+	"github.com/gookit/slog"
 
 	_ "gihub.com/gibiw/api-example/docs" // docs is generated by Swag CLI, you have to import it.
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type usecases interface {
-	GetCars(ctx context.Context) ([]entities.Car, error)
-	GetCarById(ctx context.Context, id uuid.UUID) (entities.Car, error)
+	GetCars(ctx context.Context, filter entities.CarFilter) (entities.CarsPage, error)
+	ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error
+	GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error)
+	GetStoredCar(ctx context.Context, id uuid.UUID) (entities.Car, error)
 	AddCar(ctx context.Context, car entities.Car) (entities.Car, error)
-	DeleteCarById(ctx context.Context, id uuid.UUID) error
+	ImportCars(ctx context.Context, rows []entities.ImportRow, mode entities.ImportMode) (entities.ImportReport, error)
+	DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error
 	UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	BatchCars(ctx context.Context, ops []entities.CarOperation) (entities.BatchResult, error)
+	RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error)
+	GetCarHistory(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error)
+	GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error)
 }
 
-type cache interface {
-	Set(key string, value interface{}, ttl time.Duration)
-	Get(key string) (interface{}, error)
-	Delete(key string)
+type apiKeys interface {
+	Authenticate(ctx context.Context, secret string) (entities.Principal, error)
+	CreateAPIKey(ctx context.Context, name string, role entities.Role) (entities.APIKey, string, error)
+	GetAPIKeys(ctx context.Context) ([]entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+type tokenValidator interface {
+	Validate(ctx context.Context, token string) (entities.Principal, error)
+}
+
+type metrics interface {
+	Handler() http.Handler
+	Middleware(next http.Handler) http.Handler
 }
 
 type Server struct {
-	cfg      config.Service
-	usc      usecases
-	ch       cache
-	cacheTtl time.Duration
+	cfg             config.Service
+	usc             usecases
+	readinessChecks []health.Check
+	metrics         metrics
+	keys            apiKeys
+	tokens          tokenValidator
+	limiter         rateLimiter
+	rateLimits      map[string]ratelimit.Limit
+	trustedProxies  []*net.IPNet
+}
+
+type Option func(s *Server)
+
+// WithReadinessChecks sets the checks /readyz runs.
+func WithReadinessChecks(checks ...health.Check) Option {
+	return func(s *Server) {
+		s.readinessChecks = append(s.readinessChecks, checks...)
+	}
+}
+
+// WithMetrics instruments the routes and serves the metrics on /metrics.
+func WithMetrics(m metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
 }
 
-// TODO add tests and logs
-func New(cfg config.Service, ucs usecases, ch cache) *Server {
-	return &Server{
-		cfg:      cfg,
-		usc:      ucs,
-		ch:       ch,
-		cacheTtl: time.Second * time.Duration(cfg.CacheTtlSeconds),
+// WithAPIKeys authenticates requests to the API with the keys and serves the
+// key management endpoints.
+func WithAPIKeys(k apiKeys) Option {
+	return func(s *Server) {
+		s.keys = k
 	}
 }
 
-func (s *Server) Run() error {
-	handlers := s.addHandlers()
-	return http.ListenAndServe(fmt.Sprintf("%s:%s", s.cfg.Host, s.cfg.Port), handlers)
+// WithTokenValidator accepts bearer tokens checked by v.
+func WithTokenValidator(v tokenValidator) Option {
+	return func(s *Server) {
+		s.tokens = v
+	}
+}
+
+// WithRateLimits limits the requests per caller of the route groups read,
+// write and admin. Groups missing from limits are not limited.
+func WithRateLimits(l rateLimiter, limits map[string]ratelimit.Limit) Option {
+	return func(s *Server) {
+		s.limiter = l
+		s.rateLimits = limits
+	}
+}
+
+// WithTrustedProxies takes the client address of requests from the proxies
+// from the Forwarded or X-Forwarded-For header.
+func WithTrustedProxies(proxies ...*net.IPNet) Option {
+	return func(s *Server) {
+		s.trustedProxies = append(s.trustedProxies, proxies...)
+	}
+}
+
+func New(cfg config.Service, ucs usecases, opts ...Option) *Server {
+	s := &Server{
+		cfg: cfg,
+		usc: ucs,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run serves requests until ctx is cancelled and then shuts the server down,
+// giving in-flight requests up to ShutdownTimeoutSeconds to complete.
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf("%s:%s", s.cfg.Host, s.cfg.Port),
+		Handler:      s.addHandlers(),
+		ReadTimeout:  time.Second * time.Duration(s.cfg.ReadTimeoutSeconds),
+		WriteTimeout: time.Second * time.Duration(s.cfg.WriteTimeoutSeconds),
+		IdleTimeout:  time.Second * time.Duration(s.cfg.IdleTimeoutSeconds),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(s.cfg.ShutdownTimeoutSeconds))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
 
 func (s *Server) addHandlers() *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(tracer(), traceRoute)
+	r.Use(middleware.RequestID)
+	r.Use(forwardedClient(s.trustedProxies))
+	r.Use(requestLogger(slog.Std()))
+	if len(s.cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(corsPolicy(s.cfg.CORS))
+	}
+	if s.metrics != nil {
+		r.Use(s.metrics.Middleware)
+	}
 	r.Use(setResponseHeader())
 
+	if s.metrics != nil {
+		r.Method(http.MethodGet, "/metrics", s.metrics.Handler())
+	}
+
+	r.Get("/healthz", s.liveness())
+	r.Get("/readyz", s.readiness())
+
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL(fmt.Sprintf("http://localhost:%s/swagger/doc.json", s.cfg.Port)), //The url pointing to API definition
 	))
 
-	r.Route("/cars", func(r chi.Router) {
-		r.Get("/", s.getCars())
-		r.Post("/", s.addCar())
-		r.Put("/", s.updateCar())
-		
-		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", s.getCarById())
-			r.Delete("/", s.deleteCarById())
+	r.Group(func(r chi.Router) {
+		r.Use(s.rateLimitClient(), s.authenticate)
+
+		reader := chi.Chain(requireScope(entities.ScopeCarsRead), s.rateLimit(rateLimitRead))
+		editor := chi.Chain(requireScope(entities.ScopeCarsWrite), s.rateLimit(rateLimitWrite))
+		admin := chi.Chain(requireScope(entities.ScopeCarsAdmin), s.rateLimit(rateLimitWrite))
+		auditor := chi.Chain(requireScope(entities.ScopeCarsAdmin), s.rateLimit(rateLimitRead))
+
+		r.Route("/cars", func(r chi.Router) {
+			r.With(reader...).Get("/", s.getCars())
+			r.With(reader...).Get("/export", s.exportCars())
+			r.With(editor...).Post("/", s.addCar())
+			r.With(editor...).Post("/import", s.importCars())
+			r.With(editor...).Post("/batch", s.batchCars())
+			r.With(editor...).Put("/", s.updateCar())
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.With(reader...).Get("/", s.getCarById())
+				r.With(editor...).Put("/", s.replaceCar())
+				r.With(editor...).Patch("/", s.patchCar())
+				r.With(editor...).Delete("/", s.deleteCarById())
+				r.With(admin...).Post("/restore", s.restoreCar())
+				r.With(auditor...).Get("/history", s.getCarHistory())
+				r.With(auditor...).Get("/history/{rev}/diff", s.getCarRevisionDiff())
+			})
 		})
+
+		if s.keys != nil {
+			r.Route("/admin/api-keys", func(r chi.Router) {
+				r.Use(requireScope(entities.ScopeAPIKeysManage), s.rateLimit(rateLimitAdmin))
+				r.Get("/", s.getAPIKeys())
+				r.Post("/", s.createAPIKey())
+				r.Delete("/{id}", s.revokeAPIKey())
+			})
+		}
 	})
 
 	return r
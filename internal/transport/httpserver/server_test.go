@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Run(t *testing.T) {
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		// Arrange
+		srv := New(config.Service{Host: "127.0.0.1", Port: "0", ShutdownTimeoutSeconds: 1}, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		// Act
+		go func() { done <- srv.Run(ctx) }()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		// Assert
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("server did not stop")
+		}
+	})
+
+	t.Run("returns listen error", func(t *testing.T) {
+		// Arrange
+		srv := New(config.Service{Host: "127.0.0.1", Port: "-1"}, nil)
+
+		// Act
+		err := srv.Run(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+type stubMetrics struct{}
+
+func (stubMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte("requests_total 1\n"))
+	})
+}
+
+func (stubMetrics) Middleware(next http.Handler) http.Handler {
+	return next
+}
+
+func TestServer_Metrics(t *testing.T) {
+	// Arrange
+	h := New(config.Service{}, nil, WithMetrics(stubMetrics{})).addHandlers()
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	h.ServeHTTP(w, r)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+}
@@ -0,0 +1,188 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"gihub.com/gibiw/api-example/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/gookit/slog"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type apiKeyRepository interface {
+	GetAPIKeys(ctx context.Context) ([]entities.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, error)
+	AddAPIKey(ctx context.Context, key entities.APIKey) (entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+const (
+	// apiKeyScheme starts every generated key so leaked keys are easy to
+	// find by secret scanners.
+	apiKeyScheme = "ak_"
+	apiKeyBytes  = 32
+	// apiKeyPrefixLength is how much of a key is stored in clear text.
+	apiKeyPrefixLength = len(apiKeyScheme) + 8
+)
+
+type APIKeysUsecases struct {
+	r      apiKeyRepository
+	random io.Reader
+}
+
+func NewAPIKeys(r apiKeyRepository) *APIKeysUsecases {
+	return &APIKeysUsecases{
+		r:      r,
+		random: rand.Reader,
+	}
+}
+
+// CreateAPIKey generates a new key with the role. The secret is returned
+// only here, just its hash is stored.
+func (u *APIKeysUsecases) CreateAPIKey(ctx context.Context, name string, role entities.Role) (_ entities.APIKey, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeysUsecases.CreateAPIKey")
+	defer tracing.End(span, &err)
+
+	if err = validateAPIKey(name, role); err != nil {
+		return entities.APIKey{}, "", err
+	}
+
+	buf := make([]byte, apiKeyBytes)
+	if _, err = io.ReadFull(u.random, buf); err != nil {
+		return entities.APIKey{}, "", fmt.Errorf("can not generate api key: %w", err)
+	}
+	secret := apiKeyScheme + base64.RawURLEncoding.EncodeToString(buf)
+
+	key, err := u.r.AddAPIKey(ctx, newAPIKey(name, role, secret))
+	if err != nil {
+		return entities.APIKey{}, "", err
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"api_key_id": key.Id.String(),
+		"role":       key.Role,
+	}).Info("api key created")
+
+	return key, secret, nil
+}
+
+// EnsureAPIKey stores a key with a secret chosen by the operator, e.g. the
+// bootstrap admin key, unless a key with that secret already exists.
+func (u *APIKeysUsecases) EnsureAPIKey(ctx context.Context, name string, role entities.Role, secret string) (_ entities.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeysUsecases.EnsureAPIKey")
+	defer tracing.End(span, &err)
+
+	if err = validateAPIKey(name, role); err != nil {
+		return entities.APIKey{}, err
+	}
+	if secret == "" {
+		return entities.APIKey{}, &entities.ValidationError{Fields: []entities.FieldError{{Field: "secret", Message: "is required"}}}
+	}
+
+	key, err := u.r.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, entities.ErrNotFound) {
+		return entities.APIKey{}, err
+	}
+
+	return u.r.AddAPIKey(ctx, newAPIKey(name, role, secret))
+}
+
+// Authenticate returns the caller owning the secret. Unknown and revoked
+// keys are both reported as ErrUnauthenticated.
+func (u *APIKeysUsecases) Authenticate(ctx context.Context, secret string) (_ entities.Principal, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeysUsecases.Authenticate")
+	defer tracing.End(span, &err)
+
+	if secret == "" {
+		return entities.Principal{}, fmt.Errorf("%w: api key is missing", entities.ErrUnauthenticated)
+	}
+
+	key, err := u.r.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, entities.ErrNotFound) {
+			return entities.Principal{}, fmt.Errorf("%w: unknown api key", entities.ErrUnauthenticated)
+		}
+		return entities.Principal{}, err
+	}
+	if key.RevokedAt != nil {
+		return entities.Principal{}, fmt.Errorf("%w: api key is revoked", entities.ErrUnauthenticated)
+	}
+
+	return entities.Principal{Id: key.Id.String(), Name: key.Name, Scopes: key.Role.Scopes()}, nil
+}
+
+func (u *APIKeysUsecases) GetAPIKeys(ctx context.Context) (_ []entities.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeysUsecases.GetAPIKeys")
+	defer tracing.End(span, &err)
+
+	return u.r.GetAPIKeys(ctx)
+}
+
+func (u *APIKeysUsecases) RevokeAPIKey(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "APIKeysUsecases.RevokeAPIKey")
+	defer tracing.End(span, &err)
+
+	if err = u.r.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).WithField("api_key_id", id.String()).Info("api key revoked")
+
+	return nil
+}
+
+func newAPIKey(name string, role entities.Role, secret string) entities.APIKey {
+	prefix := secret
+	if len(prefix) > apiKeyPrefixLength {
+		prefix = prefix[:apiKeyPrefixLength]
+	}
+
+	return entities.APIKey{
+		Name:   name,
+		Prefix: prefix,
+		Hash:   hashAPIKey(secret),
+		Role:   role,
+	}
+}
+
+// hashAPIKey hashes a secret for storage and lookup. Keys are random enough
+// that a fast unsalted hash can not be brute forced.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validateAPIKey(name string, role entities.Role) error {
+	fields := []entities.FieldError{}
+	switch {
+	case name == "":
+		fields = append(fields, entities.FieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString(name) > maxNameLength:
+		fields = append(fields, entities.FieldError{
+			Field:   "name",
+			Message: fmt.Sprintf("must be at most %d characters long", maxNameLength),
+		})
+	}
+	if !role.IsValid() {
+		fields = append(fields, entities.FieldError{Field: "role", Message: "must be one of reader, editor, admin"})
+	}
+
+	if len(fields) > 0 {
+		return &entities.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
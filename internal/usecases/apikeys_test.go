@@ -0,0 +1,168 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeysUsecases_CreateAPIKey(t *testing.T) {
+	t.Run("create key stores only its hash", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		var stored entities.APIKey
+		f.apiKeys.EXPECT().AddAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, key entities.APIKey) (entities.APIKey, error) {
+			stored = key
+			key.Id = uuid.New()
+			return key, nil
+		})
+		usc := NewAPIKeys(f.apiKeys)
+		usc.random = bytes.NewReader(bytes.Repeat([]byte{1}, apiKeyBytes))
+
+		// Act
+		key, secret, err := usc.CreateAPIKey(context.Background(), "importer", entities.RoleEditor)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(secret, apiKeyScheme))
+		assert.Equal(t, "ak_AQEBAQEB", stored.Prefix)
+		assert.Equal(t, hashAPIKey(secret), stored.Hash)
+		assert.Equal(t, entities.RoleEditor, key.Role)
+		assert.Equal(t, "importer", key.Name)
+	})
+
+	t.Run("create key with invalid role", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		_, _, err := usc.CreateAPIKey(context.Background(), "", "root")
+
+		// Assert
+		var validationErr *entities.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []entities.FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "role", Message: "must be one of reader, editor, admin"},
+		}, validationErr.Fields)
+	})
+}
+
+func TestAPIKeysUsecases_EnsureAPIKey(t *testing.T) {
+	t.Run("ensure existing key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		existing := entities.APIKey{Id: uuid.New(), Name: "bootstrap", Role: entities.RoleAdmin}
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), hashAPIKey("secret")).Return(existing, nil)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		key, err := usc.EnsureAPIKey(context.Background(), "bootstrap", entities.RoleAdmin, "secret")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, existing, key)
+	})
+
+	t.Run("ensure missing key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), hashAPIKey("secret")).Return(entities.APIKey{}, entities.ErrNotFound)
+		f.apiKeys.EXPECT().AddAPIKey(gomock.Any(), entities.APIKey{
+			Name:   "bootstrap",
+			Prefix: "secret",
+			Hash:   hashAPIKey("secret"),
+			Role:   entities.RoleAdmin,
+		}).Return(entities.APIKey{Name: "bootstrap"}, nil)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		key, err := usc.EnsureAPIKey(context.Background(), "bootstrap", entities.RoleAdmin, "secret")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "bootstrap", key.Name)
+	})
+}
+
+func TestAPIKeysUsecases_Authenticate(t *testing.T) {
+	t.Run("authenticate valid key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), hashAPIKey("secret")).
+			Return(entities.APIKey{Id: id, Name: "reader", Role: entities.RoleReader}, nil)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		p, err := usc.Authenticate(context.Background(), "secret")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Principal{Id: id.String(), Name: "reader", Scopes: []entities.Scope{entities.ScopeCarsRead}}, p)
+	})
+
+	t.Run("authenticate revoked key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		revokedAt := time.Now()
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), hashAPIKey("secret")).
+			Return(entities.APIKey{Id: uuid.New(), Role: entities.RoleAdmin, RevokedAt: &revokedAt}, nil)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		_, err := usc.Authenticate(context.Background(), "secret")
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+
+	t.Run("authenticate unknown key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), hashAPIKey("secret")).Return(entities.APIKey{}, entities.ErrNotFound)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		_, err := usc.Authenticate(context.Background(), "secret")
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+
+	t.Run("authenticate without key", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		_, err := usc.Authenticate(context.Background(), "")
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+
+	t.Run("authenticate with repository error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		returnErr := errors.New("text string")
+		f.apiKeys.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Return(entities.APIKey{}, returnErr)
+		usc := NewAPIKeys(f.apiKeys)
+
+		// Act
+		_, err := usc.Authenticate(context.Background(), "secret")
+
+		// Assert
+		assert.ErrorIs(t, err, returnErr)
+		assert.NotErrorIs(t, err, entities.ErrUnauthenticated)
+	})
+}
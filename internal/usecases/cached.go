@@ -0,0 +1,257 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	mycache "github.com/gibiw/cache"
+	"github.com/google/uuid"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type carsUsecases interface {
+	GetCars(ctx context.Context, filter entities.CarFilter) (entities.CarsPage, error)
+	ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error
+	GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error)
+	GetStoredCar(ctx context.Context, id uuid.UUID) (entities.Car, error)
+	AddCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	ImportCars(ctx context.Context, rows []entities.ImportRow, mode entities.ImportMode) (entities.ImportReport, error)
+	DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error
+	UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	BatchCars(ctx context.Context, ops []entities.CarOperation) (entities.BatchResult, error)
+	RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error)
+	PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error)
+	GetCarHistory(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error)
+	GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error)
+}
+
+type cache interface {
+	Set(key string, value interface{}, ttl time.Duration)
+	Get(key string) (interface{}, error)
+	Delete(key string)
+}
+
+// cacheObserver is notified about cache lookups, e.g. to export metrics.
+type cacheObserver interface {
+	CacheHit()
+	CacheMiss()
+	CacheEviction()
+}
+
+type noopCacheObserver struct{}
+
+func (noopCacheObserver) CacheHit()      {}
+func (noopCacheObserver) CacheMiss()     {}
+func (noopCacheObserver) CacheEviction() {}
+
+// CachedCarsUsecases is a cache-aside decorator for the cars usecases. Cars
+// read by id are kept in the cache. Updates store the car they wrote and
+// deletes a marker of the deleted car, and a car never replaces a newer
+// version of it in the cache. So a read racing a write can not bring back
+// the car as it was before the write.
+type CachedCarsUsecases struct {
+	next carsUsecases
+	ch   cache
+	ttl  time.Duration
+	obs  cacheObserver
+	// mu makes comparing and replacing a cached car one step.
+	mu sync.Mutex
+}
+
+type CachedOption func(c *CachedCarsUsecases)
+
+func WithCacheObserver(obs cacheObserver) CachedOption {
+	return func(c *CachedCarsUsecases) {
+		c.obs = obs
+	}
+}
+
+func NewCached(next carsUsecases, ch cache, ttl time.Duration, opts ...CachedOption) *CachedCarsUsecases {
+	c := &CachedCarsUsecases{
+		next: next,
+		ch:   ch,
+		ttl:  ttl,
+		obs:  noopCacheObserver{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *CachedCarsUsecases) GetCars(ctx context.Context, filter entities.CarFilter) (entities.CarsPage, error) {
+	return c.next.GetCars(ctx, filter)
+}
+
+// ExportCars bypasses the cache, an export reads far more cars than it holds.
+func (c *CachedCarsUsecases) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error {
+	return c.next.ExportCars(ctx, filter, fn)
+}
+
+// GetCarById bypasses the cache for lookups including deleted cars, only
+// cars that are not deleted are cached.
+func (c *CachedCarsUsecases) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
+	if includeDeleted {
+		return c.next.GetCarById(ctx, id, true)
+	}
+
+	key := id.String()
+	if car, ok := c.getValueFromCache(ctx, key); ok {
+		return car, nil
+	}
+
+	car, err := c.next.GetCarById(ctx, id, false)
+	if err != nil {
+		return entities.Car{}, err
+	}
+	c.store(car)
+
+	return car, nil
+}
+
+// GetStoredCar bypasses the cache, the caller needs the car as it is stored.
+func (c *CachedCarsUsecases) GetStoredCar(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+	return c.next.GetStoredCar(ctx, id)
+}
+
+func (c *CachedCarsUsecases) AddCar(ctx context.Context, car entities.Car) (entities.Car, error) {
+	newCar, err := c.next.AddCar(ctx, car)
+	if err != nil {
+		return entities.Car{}, err
+	}
+	c.ch.Set(newCar.Id.String(), newCar, c.ttl)
+
+	return newCar, nil
+}
+
+// ImportCars leaves the cache alone, imported cars are new and only cached
+// once read by id.
+func (c *CachedCarsUsecases) ImportCars(ctx context.Context, rows []entities.ImportRow, mode entities.ImportMode) (entities.ImportReport, error) {
+	return c.next.ImportCars(ctx, rows, mode)
+}
+
+// DeleteCarById caches a marker of the deleted car. If the delete failed the
+// car is dropped from the cache, the cached value can not be trusted after
+// that anyway.
+func (c *CachedCarsUsecases) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error {
+	if err := c.next.DeleteCarById(ctx, id, version); err != nil {
+		c.ch.Delete(id.String())
+		return err
+	}
+	c.storeDeleted(id, version)
+
+	return nil
+}
+
+// UpdateCar caches the updated car. If the update failed the car is dropped
+// from the cache.
+func (c *CachedCarsUsecases) UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error) {
+	newCar, err := c.next.UpdateCar(ctx, car)
+	if err != nil {
+		c.ch.Delete(car.Id.String())
+		return entities.Car{}, err
+	}
+	c.store(newCar)
+
+	return newCar, nil
+}
+
+// RestoreCar caches the restored car in place of the deleted marker.
+func (c *CachedCarsUsecases) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error) {
+	car, err := c.next.RestoreCar(ctx, id, version)
+	if err != nil {
+		return entities.Car{}, err
+	}
+	c.store(car)
+
+	return car, nil
+}
+
+// PurgeDeletedCars leaves the cache alone, deleted markers are never served
+// and expire with the ttl.
+func (c *CachedCarsUsecases) PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error) {
+	return c.next.PurgeDeletedCars(ctx, before)
+}
+
+// GetCarHistory is not cached, the history grows with every change.
+func (c *CachedCarsUsecases) GetCarHistory(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error) {
+	return c.next.GetCarHistory(ctx, id)
+}
+
+func (c *CachedCarsUsecases) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error) {
+	return c.next.GetCarRevision(ctx, id, revision)
+}
+
+// BatchCars caches the cars a committed batch updates or deletes like
+// UpdateCar and DeleteCarById do. If the batch was not committed they are
+// dropped from the cache.
+func (c *CachedCarsUsecases) BatchCars(ctx context.Context, ops []entities.CarOperation) (entities.BatchResult, error) {
+	res, err := c.next.BatchCars(ctx, ops)
+	for i, op := range ops {
+		switch {
+		case op.Kind == entities.OperationCreate:
+		case err != nil || !res.Committed:
+			c.ch.Delete(op.Car.Id.String())
+		case op.Kind == entities.OperationUpdate:
+			c.store(res.Results[i].Car)
+		case op.Kind == entities.OperationDelete:
+			c.storeDeleted(op.Car.Id, op.Car.Version)
+		}
+	}
+
+	return res, err
+}
+
+// store caches the car unless the cache holds the same or a newer version.
+func (c *CachedCarsUsecases) store(car entities.Car) {
+	key := car.Id.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if value, err := c.ch.Get(key); err == nil {
+		if cached, ok := value.(entities.Car); ok && cached.Version >= car.Version {
+			return
+		}
+	}
+	c.ch.Set(key, car, c.ttl)
+}
+
+// storeDeleted caches a marker of the car deleted at version. Reads of the
+// car are not served from the marker, it only keeps older versions out.
+func (c *CachedCarsUsecases) storeDeleted(id uuid.UUID, version int64) {
+	deletedAt := time.Now()
+	c.store(entities.Car{Id: id, Version: version + 1, DeletedAt: &deletedAt})
+}
+
+func (c *CachedCarsUsecases) getValueFromCache(ctx context.Context, key string) (entities.Car, bool) {
+	value, err := c.ch.Get(key)
+	if err != nil {
+		c.obs.CacheMiss()
+		if errors.Is(err, mycache.ErrorExpired) {
+			logger.FromContext(ctx).Debug("cache is expired for record with id ", key)
+			c.ch.Delete(key)
+			c.obs.CacheEviction()
+			return entities.Car{}, false
+		}
+
+		logger.FromContext(ctx).Debug(fmt.Sprintf("can not get record with id %s from cache: %s", key, err))
+
+		return entities.Car{}, false
+	}
+
+	car, ok := value.(entities.Car)
+	if !ok || car.DeletedAt != nil {
+		c.obs.CacheMiss()
+		return entities.Car{}, false
+	}
+	c.obs.CacheHit()
+
+	return car, true
+}
@@ -0,0 +1,427 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	mycache "github.com/gibiw/cache"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTtl = time.Minute
+
+func TestCachedCarsUsecases_GetCars(t *testing.T) {
+	t.Run("get cars bypasses cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		page := entities.CarsPage{Cars: []entities.Car{{Id: uuid.New(), Brand: "Audi"}}}
+		f.usecases.EXPECT().GetCars(gomock.Any(), entities.CarFilter{}).Return(page, nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCars(context.Background(), entities.CarFilter{})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, page, reps)
+	})
+}
+
+func TestCachedCarsUsecases_ExportCars(t *testing.T) {
+	t.Run("export cars bypasses cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		filter := entities.CarFilter{Brand: "Audi"}
+		f.usecases.EXPECT().ExportCars(gomock.Any(), filter, gomock.Any()).Return(nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		err := usc.ExportCars(context.Background(), filter, func(entities.Car) error { return nil })
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestCachedCarsUsecases_GetCarById(t *testing.T) {
+	t.Run("get car from cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.cache.EXPECT().Get(id.String()).Return(car, nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("get deleted car bypasses cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", DeletedAt: &time.Time{}}
+		f.usecases.EXPECT().GetCarById(gomock.Any(), id, true).Return(car, nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, true)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("get car on cache miss", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.cache.EXPECT().Get(id.String()).Return(nil, mycache.ErrorNotFound).Times(2)
+		f.usecases.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, nil)
+		f.cache.EXPECT().Set(id.String(), car, testTtl)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("get car keeps newer version in cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 1}
+		updated := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 9000, Version: 2}
+		gomock.InOrder(
+			f.cache.EXPECT().Get(id.String()).Return(nil, mycache.ErrorNotFound),
+			f.usecases.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, nil),
+			f.cache.EXPECT().Get(id.String()).Return(updated, nil),
+		)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("get deleted car is not served from cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		marker := entities.Car{Id: id, Version: 2, DeletedAt: &time.Time{}}
+		f.cache.EXPECT().Get(id.String()).Return(marker, nil)
+		f.usecases.EXPECT().GetCarById(gomock.Any(), id, false).Return(entities.Car{}, entities.ErrNotFound)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		_, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+
+	t.Run("get car with expired value", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		gomock.InOrder(
+			f.cache.EXPECT().Get(id.String()).Return(nil, mycache.ErrorExpired),
+			f.cache.EXPECT().Delete(id.String()),
+			f.usecases.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, nil),
+			f.cache.EXPECT().Get(id.String()).Return(nil, mycache.ErrorNotFound),
+			f.cache.EXPECT().Set(id.String(), car, testTtl),
+		)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("get car notifies observer", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		hit, miss, expired := uuid.New(), uuid.New(), uuid.New()
+		car := entities.Car{Id: hit, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.cache.EXPECT().Get(hit.String()).Return(car, nil)
+		f.cache.EXPECT().Get(miss.String()).Return(nil, mycache.ErrorNotFound).Times(2)
+		f.cache.EXPECT().Get(expired.String()).Return(nil, mycache.ErrorExpired)
+		f.cache.EXPECT().Delete(expired.String())
+		f.cache.EXPECT().Get(expired.String()).Return(nil, mycache.ErrorNotFound)
+		f.cache.EXPECT().Set(gomock.Any(), gomock.Any(), testTtl).Times(2)
+		f.usecases.EXPECT().GetCarById(gomock.Any(), gomock.Any(), false).DoAndReturn(
+			func(_ context.Context, id uuid.UUID, _ bool) (entities.Car, error) {
+				return entities.Car{Id: id}, nil
+			}).Times(2)
+		f.observer.EXPECT().CacheHit()
+		f.observer.EXPECT().CacheMiss().Times(2)
+		f.observer.EXPECT().CacheEviction()
+		usc := NewCached(f.usecases, f.cache, testTtl, WithCacheObserver(f.observer))
+
+		// Act
+		for _, id := range []uuid.UUID{hit, miss, expired} {
+			_, err := usc.GetCarById(context.Background(), id, false)
+
+			// Assert
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("get car with error is not cached", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		returnErr := errors.New("text string")
+		f.cache.EXPECT().Get(id.String()).Return(nil, mycache.ErrorNotFound)
+		f.usecases.EXPECT().GetCarById(gomock.Any(), id, false).Return(entities.Car{}, returnErr)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.ErrorIs(t, err, returnErr)
+		assert.Equal(t, entities.Car{}, reps)
+	})
+}
+
+func TestCachedCarsUsecases_GetStoredCar(t *testing.T) {
+	t.Run("get stored car bypasses cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.usecases.EXPECT().GetStoredCar(gomock.Any(), id).Return(car, nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.GetStoredCar(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+}
+
+func TestCachedCarsUsecases_AddCar(t *testing.T) {
+	t.Run("add car stores it in cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		car := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		newCar := car
+		newCar.Id = uuid.New()
+		f.usecases.EXPECT().AddCar(gomock.Any(), car).Return(newCar, nil)
+		f.cache.EXPECT().Set(newCar.Id.String(), newCar, testTtl)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.AddCar(context.Background(), car)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, newCar, reps)
+	})
+
+	t.Run("add car with error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		returnErr := errors.New("text string")
+		car := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.usecases.EXPECT().AddCar(gomock.Any(), car).Return(entities.Car{}, returnErr)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.AddCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, returnErr)
+		assert.Equal(t, entities.Car{}, reps)
+	})
+}
+
+func TestCachedCarsUsecases_ImportCars(t *testing.T) {
+	t.Run("import cars bypasses cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		rows := []entities.ImportRow{{Row: 1, Car: entities.Car{Brand: "Audi"}}}
+		f.usecases.EXPECT().ImportCars(gomock.Any(), rows, entities.ImportPartial).Return(entities.ImportReport{Accepted: 1}, nil)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), rows, entities.ImportPartial)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ImportReport{Accepted: 1}, report)
+	})
+}
+
+func TestCachedCarsUsecases_BatchCars(t *testing.T) {
+	t.Run("committed batch caches updated and deleted cars", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		updated := entities.Car{Id: uuid.New(), Brand: "Audi", Version: 3}
+		deleted := uuid.New()
+		ops := []entities.CarOperation{
+			{Kind: entities.OperationUpdate, Car: entities.Car{Id: updated.Id, Version: 2}},
+			{Kind: entities.OperationDelete, Car: entities.Car{Id: deleted, Version: 1}},
+		}
+		f.usecases.EXPECT().BatchCars(gomock.Any(), ops).Return(entities.BatchResult{
+			Committed: true,
+			Results:   []entities.CarOperationResult{{Car: updated}, {}},
+		}, nil)
+		f.cache.EXPECT().Get(gomock.Any()).Return(nil, mycache.ErrorNotFound).Times(2)
+		f.cache.EXPECT().Set(updated.Id.String(), updated, testTtl)
+		f.cache.EXPECT().Set(deleted.String(), gomock.Any(), testTtl)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		_, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("rolled back batch drops updated and deleted cars from cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		updated, deleted := uuid.New(), uuid.New()
+		ops := []entities.CarOperation{
+			{Kind: entities.OperationCreate, Car: entities.Car{Brand: "Audi"}},
+			{Kind: entities.OperationUpdate, Car: entities.Car{Id: updated}},
+			{Kind: entities.OperationDelete, Car: entities.Car{Id: deleted}},
+		}
+		f.usecases.EXPECT().BatchCars(gomock.Any(), ops).Return(entities.BatchResult{}, nil)
+		f.cache.EXPECT().Delete(updated.String())
+		f.cache.EXPECT().Delete(deleted.String())
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		_, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestCachedCarsUsecases_DeleteCarById(t *testing.T) {
+	t.Run("delete car caches deleted marker", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		var marker entities.Car
+		gomock.InOrder(
+			f.usecases.EXPECT().DeleteCarById(gomock.Any(), id, int64(1)).Return(nil),
+			f.cache.EXPECT().Get(id.String()).Return(entities.Car{Id: id, Version: 1}, nil),
+			f.cache.EXPECT().Set(id.String(), gomock.Any(), testTtl).Do(func(_ string, value interface{}, _ time.Duration) {
+				marker = value.(entities.Car)
+			}),
+		)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		err := usc.DeleteCarById(context.Background(), id, 1)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), marker.Version)
+		assert.NotNil(t, marker.DeletedAt)
+	})
+
+	t.Run("delete car with error invalidates cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		returnErr := errors.New("text string")
+		f.usecases.EXPECT().DeleteCarById(gomock.Any(), id, int64(1)).Return(returnErr)
+		f.cache.EXPECT().Delete(id.String())
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		err := usc.DeleteCarById(context.Background(), id, 1)
+
+		// Assert
+		assert.ErrorIs(t, err, returnErr)
+	})
+}
+
+func TestCachedCarsUsecases_UpdateCar(t *testing.T) {
+	t.Run("update car caches updated car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Green", Cost: 10000, Version: 2}
+		gomock.InOrder(
+			f.usecases.EXPECT().UpdateCar(gomock.Any(), car).Return(car, nil),
+			f.cache.EXPECT().Get(car.Id.String()).Return(entities.Car{Id: car.Id, Version: 1}, nil),
+			f.cache.EXPECT().Set(car.Id.String(), car, testTtl),
+		)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.UpdateCar(context.Background(), car)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+
+	t.Run("update car with error invalidates cache", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		returnErr := errors.New("text string")
+		car := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Green", Cost: 10000}
+		f.usecases.EXPECT().UpdateCar(gomock.Any(), car).Return(entities.Car{}, returnErr)
+		f.cache.EXPECT().Delete(car.Id.String())
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.UpdateCar(context.Background(), car)
+
+		// Assert
+		assert.ErrorIs(t, err, returnErr)
+		assert.Equal(t, entities.Car{}, reps)
+	})
+}
+
+func TestCachedCarsUsecases_RestoreCar(t *testing.T) {
+	t.Run("restore car replaces deleted marker", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Green", Cost: 10000, Version: 3}
+		marker := entities.Car{Id: id, Version: 2, DeletedAt: &time.Time{}}
+		gomock.InOrder(
+			f.usecases.EXPECT().RestoreCar(gomock.Any(), id, int64(2)).Return(car, nil),
+			f.cache.EXPECT().Get(id.String()).Return(marker, nil),
+			f.cache.EXPECT().Set(id.String(), car, testTtl),
+		)
+		usc := NewCached(f.usecases, f.cache, testTtl)
+
+		// Act
+		reps, err := usc.RestoreCar(context.Background(), id, 2)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
+}
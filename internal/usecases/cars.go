@@ -2,47 +2,510 @@ package usecases
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"gihub.com/gibiw/api-example/internal/entities"
+	"gihub.com/gibiw/api-example/internal/logger"
+	"gihub.com/gibiw/api-example/internal/tracing"
 	"github.com/google/uuid"
+	"github.com/gookit/slog"
 )
 
 //go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
 type repository interface {
-	GetCars(ctx context.Context) ([]entities.Car, error)
-	GetCarById(ctx context.Context, id uuid.UUID) (entities.Car, error)
+	GetCars(ctx context.Context, filter entities.CarFilter) ([]entities.Car, error)
+	ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error
+	GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error)
 	AddCar(ctx context.Context, car entities.Car) (entities.Car, error)
-	DeleteCarById(ctx context.Context, id uuid.UUID) error
+	ImportCars(ctx context.Context, batches [][]entities.Car) error
+	DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error
 	UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error)
+	RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error)
+	PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error)
+	GetCarRevisions(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error)
+	GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error)
 }
 
-// TODO add logs
+// txManager runs fn in a transaction that repository calls made with the
+// context passed to fn take part in. fn may run more than once.
+type txManager interface {
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	// importBatchSize is how many cars are stored with one COPY.
+	importBatchSize = 1000
+	// maxBatchOperations bounds the operations of a batch, they all run in
+	// one transaction.
+	maxBatchOperations = 100
+)
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
 type CarsUsecases struct {
-	r repository
+	r  repository
+	tx txManager
 }
 
-func New(r repository) *CarsUsecases {
+func New(r repository, tx txManager) *CarsUsecases {
 	return &CarsUsecases{
-		r: r,
+		r:  r,
+		tx: tx,
+	}
+}
+
+// GetCars returns a page of cars matching the filter. The next page starts
+// after the returned cursor, which is nil on the last page.
+func (c *CarsUsecases) GetCars(ctx context.Context, filter entities.CarFilter) (_ entities.CarsPage, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.GetCars")
+	defer tracing.End(span, &err)
+
+	if filter.SortBy == "" {
+		filter.SortBy = entities.SortById
+	}
+	if filter.Order == "" {
+		filter.Order = entities.OrderAsc
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	// Ask for one more row to find out whether there is a next page.
+	filter.Limit = limit + 1
+
+	cars, err := c.r.GetCars(ctx, filter)
+	if err != nil {
+		return entities.CarsPage{}, err
+	}
+
+	page := entities.CarsPage{Cars: cars}
+	if uint64(len(cars)) > limit {
+		page.Cars = cars[:limit]
+		last := page.Cars[limit-1]
+		page.Next = &entities.Cursor{Value: last.SortValue(filter.SortBy), Id: last.Id}
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"count":     len(page.Cars),
+		"has_next":  page.Next != nil,
+		"sort_by":   filter.SortBy,
+		"sort_desc": filter.Order == entities.OrderDesc,
+	}).Debug("cars page loaded")
+
+	return page, nil
+}
+
+// ExportCars calls fn for every car matching the filter, sorted like a page
+// of GetCars but without a limit. The cursor and limit of the filter are
+// ignored.
+func (c *CarsUsecases) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) (err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.ExportCars")
+	defer tracing.End(span, &err)
+
+	if filter.SortBy == "" {
+		filter.SortBy = entities.SortById
+	}
+	if filter.Order == "" {
+		filter.Order = entities.OrderAsc
+	}
+	filter.Limit = 0
+	filter.After = nil
+
+	count := 0
+	err = c.r.ExportCars(ctx, filter, func(car entities.Car) error {
+		count++
+		return fn(car)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).WithField("count", count).Info("cars exported")
+
+	return nil
+}
+
+// GetCarById returns the car with the id. A deleted car is only returned
+// with includeDeleted.
+func (c *CarsUsecases) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (_ entities.Car, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.GetCarById")
+	defer tracing.End(span, &err)
+
+	return c.r.GetCarById(ctx, id, includeDeleted)
+}
+
+// GetStoredCar returns the car with the id as it is stored, decorators of
+// the usecases such as the cache pass it through. Deleted cars are not
+// returned.
+func (c *CarsUsecases) GetStoredCar(ctx context.Context, id uuid.UUID) (_ entities.Car, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.GetStoredCar")
+	defer tracing.End(span, &err)
+
+	return c.r.GetCarById(ctx, id, false)
+}
+
+func (c *CarsUsecases) AddCar(ctx context.Context, car entities.Car) (_ entities.Car, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.AddCar")
+	defer tracing.End(span, &err)
+
+	if err = validateCar(car); err != nil {
+		logger.FromContext(ctx).Debug("invalid car: ", err)
+		return entities.Car{}, err
+	}
+
+	newCar, err := c.r.AddCar(ctx, car)
+	if err != nil {
+		return entities.Car{}, err
+	}
+
+	logger.FromContext(ctx).WithField("car_id", newCar.Id.String()).Info("car added")
+
+	return newCar, nil
+}
+
+// ImportCars validates the rows and stores the valid ones in batches. The
+// rows the database refuses are reported as rejected. In partial mode the
+// import goes on, in all-or-nothing mode nothing is stored if any row is
+// rejected or any batch fails.
+func (c *CarsUsecases) ImportCars(ctx context.Context, rows []entities.ImportRow, mode entities.ImportMode) (_ entities.ImportReport, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.ImportCars")
+	defer tracing.End(span, &err)
+
+	report := entities.ImportReport{}
+	valid := make([]entities.ImportRow, 0, len(rows))
+	for _, row := range rows {
+		if rejected, ok := rejectRow(row); ok {
+			report.Rejected = append(report.Rejected, rejected)
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	var batches [][]entities.ImportRow
+	for len(valid) > importBatchSize {
+		batches = append(batches, valid[:importBatchSize])
+		valid = valid[importBatchSize:]
+	}
+	if len(valid) > 0 {
+		batches = append(batches, valid)
+	}
+
+	if mode == entities.ImportAllOrNothing {
+		if len(report.Rejected) > 0 {
+			report.RolledBack = true
+		} else if err = c.r.ImportCars(ctx, importBatchCars(batches...)); err != nil {
+			if !isRowError(err) {
+				return entities.ImportReport{}, err
+			}
+			// Nothing was stored. The refused rows are looked for with
+			// imports that are rolled back, so they can be reported.
+			for _, batch := range batches {
+				rejected, err := bisectImport(ctx, batch, c.dryRunImport)
+				if err != nil {
+					return entities.ImportReport{}, err
+				}
+				report.Rejected = append(report.Rejected, rejected...)
+			}
+			if len(report.Rejected) == 0 {
+				// Only the rows together were refused.
+				return entities.ImportReport{}, err
+			}
+			report.RolledBack = true
+		}
+	} else {
+		for _, batch := range batches {
+			rejected, err := bisectImport(ctx, batch, c.importRows)
+			if err != nil {
+				return entities.ImportReport{}, err
+			}
+			report.Rejected = append(report.Rejected, rejected...)
+		}
+	}
+	sort.Slice(report.Rejected, func(i, j int) bool {
+		return report.Rejected[i].Row < report.Rejected[j].Row
+	})
+
+	if !report.RolledBack {
+		report.Accepted = len(rows) - len(report.Rejected)
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"mode":        mode,
+		"accepted":    report.Accepted,
+		"rejected":    len(report.Rejected),
+		"rolled_back": report.RolledBack,
+	}).Info("cars imported")
+
+	return report, nil
+}
+
+// bisectImport imports the rows with importFn. If the database refuses them
+// the rows are split in halves that are imported on their own, so only the
+// rows at fault are rejected. k refused rows of n cost O(k log n) imports.
+func bisectImport(ctx context.Context, rows []entities.ImportRow, importFn func(ctx context.Context, rows []entities.ImportRow) error) ([]entities.RejectedRow, error) {
+	err := importFn(ctx, rows)
+	if err == nil || !isRowError(err) {
+		return nil, err
+	}
+
+	if len(rows) == 1 {
+		return []entities.RejectedRow{{Row: rows[0].Row, Reason: err.Error()}}, nil
+	}
+
+	half := len(rows) / 2
+	rejected, err := bisectImport(ctx, rows[:half], importFn)
+	if err != nil {
+		return nil, err
 	}
+	rest, err := bisectImport(ctx, rows[half:], importFn)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rejected, rest...), nil
+}
+
+// importRows stores the rows with one COPY.
+func (c *CarsUsecases) importRows(ctx context.Context, rows []entities.ImportRow) error {
+	return c.r.ImportCars(ctx, importBatchCars(rows))
+}
+
+// dryRunImport tells whether the database accepts the rows by importing them
+// in a transaction that is rolled back.
+func (c *CarsUsecases) dryRunImport(ctx context.Context, rows []entities.ImportRow) error {
+	err := c.tx.RunInTx(ctx, func(ctx context.Context) error {
+		if err := c.importRows(ctx, rows); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+
+	return err
+}
+
+// isRowError tells whether the database refused rows for their data. Any
+// other error fails the whole import.
+func isRowError(err error) bool {
+	return errors.Is(err, entities.ErrValidation) || errors.Is(err, entities.ErrConflict)
+}
+
+// rejectRow tells whether a row can not be imported and why.
+func rejectRow(row entities.ImportRow) (entities.RejectedRow, bool) {
+	if row.Err != nil {
+		return entities.RejectedRow{Row: row.Row, Reason: row.Err.Error()}, true
+	}
+
+	err := validateCar(row.Car)
+	if err == nil {
+		return entities.RejectedRow{}, false
+	}
+	rejected := entities.RejectedRow{Row: row.Row, Reason: entities.ErrValidation.Error()}
+	var validationErr *entities.ValidationError
+	if errors.As(err, &validationErr) {
+		rejected.Fields = validationErr.Fields
+	}
+
+	return rejected, true
+}
+
+func importBatchCars(batches ...[]entities.ImportRow) [][]entities.Car {
+	cars := make([][]entities.Car, 0, len(batches))
+	for _, batch := range batches {
+		b := make([]entities.Car, 0, len(batch))
+		for _, row := range batch {
+			b = append(b, row.Car)
+		}
+		cars = append(cars, b)
+	}
+
+	return cars
+}
+
+// BatchCars runs the operations in order in one transaction. The first
+// operation that fails for its car stops the batch and rolls back the
+// operations before it. Any other error, e.g. a failed commit or a
+// serialization failure the transaction gave up retrying, is returned as
+// error.
+func (c *CarsUsecases) BatchCars(ctx context.Context, ops []entities.CarOperation) (_ entities.BatchResult, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.BatchCars")
+	defer tracing.End(span, &err)
+
+	if len(ops) == 0 || len(ops) > maxBatchOperations {
+		return entities.BatchResult{}, &entities.ValidationError{Fields: []entities.FieldError{{
+			Field:   "operations",
+			Message: fmt.Sprintf("must have between 1 and %d operations", maxBatchOperations),
+		}}}
+	}
+
+	var results []entities.CarOperationResult
+	failed := false
+	err = c.tx.RunInTx(ctx, func(ctx context.Context) error {
+		// A retried transaction runs all operations again.
+		results, failed = make([]entities.CarOperationResult, 0, len(ops)), false
+		for _, op := range ops {
+			car, err := c.runOperation(ctx, op)
+			if err != nil && !isOperationError(err) {
+				return err
+			}
+			results = append(results, entities.CarOperationResult{Car: car, Err: err})
+			if err != nil {
+				failed = true
+				return err
+			}
+		}
+		return nil
+	})
+	if failed {
+		logger.FromContext(ctx).WithFields(slog.M{
+			"operations": len(ops),
+			"failed":     len(results) - 1,
+		}).Info("car batch rolled back")
+		return entities.BatchResult{Results: results}, nil
+	}
+	if err != nil {
+		return entities.BatchResult{}, err
+	}
+
+	logger.FromContext(ctx).WithField("operations", len(ops)).Info("car batch committed")
+
+	return entities.BatchResult{Committed: true, Results: results}, nil
+}
+
+// isOperationError tells whether an operation of a batch failed for its car,
+// rather than for the database or the transaction.
+func isOperationError(err error) bool {
+	return errors.Is(err, entities.ErrValidation) ||
+		errors.Is(err, entities.ErrNotFound) ||
+		errors.Is(err, entities.ErrConflict) ||
+		errors.Is(err, entities.ErrVersionMismatch)
 }
 
-func (c *CarsUsecases) GetCars(ctx context.Context) ([]entities.Car, error) {
-	return c.r.GetCars(ctx)
+func (c *CarsUsecases) runOperation(ctx context.Context, op entities.CarOperation) (entities.Car, error) {
+	switch op.Kind {
+	case entities.OperationCreate:
+		return c.AddCar(ctx, op.Car)
+	case entities.OperationUpdate:
+		return c.UpdateCar(ctx, op.Car)
+	case entities.OperationDelete:
+		return entities.Car{}, c.DeleteCarById(ctx, op.Car.Id, op.Car.Version)
+	default:
+		return entities.Car{}, &entities.ValidationError{Fields: []entities.FieldError{{
+			Field:   "op",
+			Message: fmt.Sprintf("unknown operation %q", op.Kind),
+		}}}
+	}
 }
 
-func (c *CarsUsecases) GetCarById(ctx context.Context, id uuid.UUID) (entities.Car, error) {
-	return c.r.GetCarById(ctx, id)
+func (c *CarsUsecases) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) (err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.DeleteCarById")
+	defer tracing.End(span, &err)
+
+	if err = c.r.DeleteCarById(ctx, id, version); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).WithField("car_id", id.String()).Info("car deleted")
+
+	return nil
 }
 
-func (c *CarsUsecases) AddCar(ctx context.Context, car entities.Car) (entities.Car, error) {
-	return c.r.AddCar(ctx, car)
+// RestoreCar undoes the delete of a car that has not been purged yet.
+func (c *CarsUsecases) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (_ entities.Car, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.RestoreCar")
+	defer tracing.End(span, &err)
+
+	car, err := c.r.RestoreCar(ctx, id, version)
+	if err != nil {
+		return entities.Car{}, err
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"car_id":  car.Id.String(),
+		"version": car.Version,
+	}).Info("car restored")
+
+	return car, nil
 }
 
-func (c *CarsUsecases) DeleteCarById(ctx context.Context, id uuid.UUID) error {
-	return c.r.DeleteCarById(ctx, id)
+// GetCarHistory returns the changes of the car, oldest first. The history
+// of a purged car is kept. A car stored before its changes were recorded
+// has an empty history.
+func (c *CarsUsecases) GetCarHistory(ctx context.Context, id uuid.UUID) (_ []entities.CarRevision, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.GetCarHistory")
+	defer tracing.End(span, &err)
+
+	revisions, err := c.r.GetCarRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		if _, err := c.r.GetCarById(ctx, id, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return revisions, nil
 }
 
-func (c *CarsUsecases) UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error) {
-	return c.r.UpdateCar(ctx, car)
+func (c *CarsUsecases) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (_ entities.CarRevision, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.GetCarRevision")
+	defer tracing.End(span, &err)
+
+	return c.r.GetCarRevision(ctx, id, revision)
+}
+
+// PurgeDeletedCars removes the cars deleted before the given time for good.
+func (c *CarsUsecases) PurgeDeletedCars(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.PurgeDeletedCars")
+	defer tracing.End(span, &err)
+
+	n, err := c.r.PurgeDeletedCars(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 0 {
+		logger.FromContext(ctx).WithFields(slog.M{
+			"cars":   n,
+			"before": before.Format(time.RFC3339),
+		}).Info("deleted cars purged")
+	}
+
+	return n, nil
+}
+
+func (c *CarsUsecases) UpdateCar(ctx context.Context, car entities.Car) (_ entities.Car, err error) {
+	ctx, span := tracing.Start(ctx, "CarsUsecases.UpdateCar")
+	defer tracing.End(span, &err)
+
+	if err = validateCar(car); err != nil {
+		logger.FromContext(ctx).Debug("invalid car: ", err)
+		return entities.Car{}, err
+	}
+
+	newCar, err := c.r.UpdateCar(ctx, car)
+	if err != nil {
+		return entities.Car{}, err
+	}
+
+	logger.FromContext(ctx).WithFields(slog.M{
+		"car_id":  newCar.Id.String(),
+		"version": newCar.Version,
+	}).Info("car updated")
+
+	return newCar, nil
 }
@@ -3,12 +3,19 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"gihub.com/gibiw/api-example/internal/entities"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestCarsUsecases_GetCars(t *testing.T) {
@@ -31,33 +38,154 @@ func TestCarsUsecases_GetCars(t *testing.T) {
 				Cost:  8000,
 			},
 		}
-		f.repository.EXPECT().GetCars(gomock.Any()).Return(cars, nil)
-		usc := New(f.repository)
+		f.repository.EXPECT().GetCars(gomock.Any(), entities.CarFilter{
+			SortBy: entities.SortById,
+			Order:  entities.OrderAsc,
+			Limit:  defaultLimit + 1,
+		}).Return(cars, nil)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		reps, err := usc.GetCars(context.Background())
+		reps, err := usc.GetCars(context.Background(), entities.CarFilter{})
 
 		// Assert
 		assert.NoError(t, err)
-		assert.ElementsMatch(t, cars, reps)
+		assert.ElementsMatch(t, cars, reps.Cars)
+		assert.Nil(t, reps.Next)
+	})
+
+	t.Run("get cars with next page", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		cars := []entities.Car{
+			{
+				Id:    uuid.New(),
+				Brand: "Audi",
+				Model: "A3",
+				Color: "Red",
+				Cost:  10000,
+			},
+			{
+				Id:    uuid.New(),
+				Brand: "Ford",
+				Model: "Focus",
+				Color: "Green",
+				Cost:  8000,
+			},
+		}
+		filter := entities.CarFilter{
+			SortBy: entities.SortByCost,
+			Order:  entities.OrderDesc,
+			Limit:  1,
+		}
+		f.repository.EXPECT().GetCars(gomock.Any(), entities.CarFilter{
+			SortBy: entities.SortByCost,
+			Order:  entities.OrderDesc,
+			Limit:  2,
+		}).Return(cars, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		reps, err := usc.GetCars(context.Background(), filter)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, cars[:1], reps.Cars)
+		assert.Equal(t, &entities.Cursor{Value: "10000", Id: cars[0].Id}, reps.Next)
+	})
+
+	t.Run("get cars with limit above maximum", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.repository.EXPECT().GetCars(gomock.Any(), entities.CarFilter{
+			SortBy: entities.SortById,
+			Order:  entities.OrderAsc,
+			Limit:  maxLimit + 1,
+		}).Return([]entities.Car{}, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		reps, err := usc.GetCars(context.Background(), entities.CarFilter{Limit: 1000})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, reps.Cars)
 	})
 
 	t.Run("get cars with error", func(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
 		returnErr := errors.New("text string")
-		f.repository.EXPECT().GetCars(gomock.Any()).Return(nil, returnErr)
-		usc := New(f.repository)
+		f.repository.EXPECT().GetCars(gomock.Any(), gomock.Any()).Return(nil, returnErr)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		reps, err := usc.GetCars(context.Background())
+		reps, err := usc.GetCars(context.Background(), entities.CarFilter{})
 
 		// Assert
-		assert.Nil(t, reps)
+		assert.Nil(t, reps.Cars)
 		assert.Error(t, returnErr, err)
 	})
 }
 
+func TestCarsUsecases_ExportCars(t *testing.T) {
+	t.Run("export cars without error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		cars := []entities.Car{
+			{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000},
+			{Id: uuid.New(), Brand: "Ford", Model: "Focus", Color: "Green", Cost: 8000},
+		}
+		f.repository.EXPECT().ExportCars(gomock.Any(), entities.CarFilter{
+			Brand:  "Audi",
+			SortBy: entities.SortById,
+			Order:  entities.OrderAsc,
+		}, gomock.Any()).DoAndReturn(func(_ context.Context, _ entities.CarFilter, fn func(entities.Car) error) error {
+			for _, c := range cars {
+				if err := fn(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		usc := New(f.repository, f.tx)
+		exported := []entities.Car{}
+
+		// Act
+		err := usc.ExportCars(context.Background(), entities.CarFilter{
+			Brand: "Audi",
+			Limit: 10,
+			After: &entities.Cursor{Id: uuid.New()},
+		}, func(c entities.Car) error {
+			exported = append(exported, c)
+			return nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, cars, exported)
+	})
+
+	t.Run("export cars stops on callback error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		expectedErr := errors.New("client gone")
+		f.repository.EXPECT().ExportCars(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ entities.CarFilter, fn func(entities.Car) error) error {
+				return fn(entities.Car{Id: uuid.New()})
+			})
+		usc := New(f.repository, f.tx)
+
+		// Act
+		err := usc.ExportCars(context.Background(), entities.CarFilter{}, func(entities.Car) error {
+			return expectedErr
+		})
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+	})
+}
+
 func TestCarsUsecases_GetCarById(t *testing.T) {
 	t.Run("get car by id without error", func(t *testing.T) {
 		// Arrange
@@ -71,11 +199,11 @@ func TestCarsUsecases_GetCarById(t *testing.T) {
 			Color: "Red",
 			Cost:  10000,
 		}
-		f.repository.EXPECT().GetCarById(gomock.Any(), id).Return(car, nil)
-		usc := New(f.repository)
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, nil)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		reps, err := usc.GetCarById(context.Background(), id)
+		reps, err := usc.GetCarById(context.Background(), id, false)
 
 		// Assert
 		assert.NoError(t, err)
@@ -88,16 +216,59 @@ func TestCarsUsecases_GetCarById(t *testing.T) {
 		returnErr := errors.New("text string")
 		id := uuid.New()
 		car := entities.Car{}
-		f.repository.EXPECT().GetCarById(gomock.Any(), id).Return(car, returnErr)
-		usc := New(f.repository)
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, returnErr)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		reps, err := usc.GetCarById(context.Background(), id)
+		reps, err := usc.GetCarById(context.Background(), id, false)
 
 		// Assert
 		assert.Equal(t, car, reps)
 		assert.Error(t, returnErr, err)
 	})
+
+	t.Run("get car by id records span", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		id := uuid.New()
+		var repositorySpan trace.SpanContext
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, false).DoAndReturn(func(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
+			repositorySpan = trace.SpanContextFromContext(ctx)
+			return entities.Car{}, entities.ErrNotFound
+		})
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.GetCarById(context.Background(), id, false)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+		spans := recorder.Ended()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "CarsUsecases.GetCarById", spans[0].Name())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, spans[0].SpanContext(), repositorySpan)
+	})
+}
+
+func TestCarsUsecases_GetStoredCar(t *testing.T) {
+	t.Run("get stored car excludes deleted cars", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, false).Return(car, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		reps, err := usc.GetStoredCar(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, reps)
+	})
 }
 
 func TestCarsUsecases_AddCar(t *testing.T) {
@@ -111,7 +282,7 @@ func TestCarsUsecases_AddCar(t *testing.T) {
 			Cost:  10000,
 		}
 		f.repository.EXPECT().AddCar(gomock.Any(), car).Return(car, nil)
-		usc := New(f.repository)
+		usc := New(f.repository, f.tx)
 
 		// Act
 		reps, err := usc.AddCar(context.Background(), car)
@@ -132,7 +303,7 @@ func TestCarsUsecases_AddCar(t *testing.T) {
 			Cost:  10000,
 		}
 		f.repository.EXPECT().AddCar(gomock.Any(), car).Return(entities.Car{}, returnErr)
-		usc := New(f.repository)
+		usc := New(f.repository, f.tx)
 
 		// Act
 		reps, err := usc.AddCar(context.Background(), car)
@@ -141,6 +312,353 @@ func TestCarsUsecases_AddCar(t *testing.T) {
 		assert.Equal(t, entities.Car{}, reps)
 		assert.Error(t, returnErr, err)
 	})
+
+	t.Run("add invalid car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		car := entities.Car{
+			Brand: "Audi",
+			Color: "Red",
+		}
+		usc := New(f.repository, f.tx)
+
+		// Act
+		reps, err := usc.AddCar(context.Background(), car)
+
+		// Assert
+		assert.Equal(t, entities.Car{}, reps)
+		assert.ErrorIs(t, err, entities.ErrValidation)
+	})
+}
+
+func TestCarsUsecases_ImportCars(t *testing.T) {
+	audi := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+	ford := entities.Car{Brand: "Ford", Model: "Focus", Color: "Green", Cost: 8000}
+	rows := []entities.ImportRow{
+		{Row: 1, Car: audi},
+		{Row: 2, Car: entities.Car{Brand: "Audi", Model: "A4", Color: "Blue"}},
+		{Row: 3, Err: errors.New("invalid JSON")},
+		{Row: 4, Car: ford},
+	}
+	rejected := []entities.RejectedRow{
+		{Row: 2, Reason: "validation failed", Fields: []entities.FieldError{{Field: "cost", Message: "must be between 1 and 1000000000"}}},
+		{Row: 3, Reason: "invalid JSON"},
+	}
+
+	t.Run("partial import stores valid rows", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi, ford}}).Return(nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), rows, entities.ImportPartial)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ImportReport{Accepted: 2, Rejected: rejected}, report)
+	})
+
+	t.Run("partial import rejects rows refused by repository", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		tooLong := fmt.Errorf("%w: value is too long", entities.ErrValidation)
+		gomock.InOrder(
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi, ford}}).Return(tooLong),
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi}}).Return(nil),
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{ford}}).Return(tooLong),
+		)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), rows, entities.ImportPartial)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Accepted)
+		assert.Len(t, report.Rejected, 3)
+		assert.Equal(t, entities.RejectedRow{Row: 4, Reason: "validation failed: value is too long"}, report.Rejected[2])
+	})
+
+	t.Run("partial import fails on repository error of a row", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		expectedErr := errors.New("connection refused")
+		gomock.InOrder(
+			f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Any()).Return(fmt.Errorf("%w: value already exists", entities.ErrConflict)),
+			f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Any()).Return(expectedErr),
+		)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.ImportCars(context.Background(), rows, entities.ImportPartial)
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+	})
+
+	t.Run("partial import fails on repository error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		expectedErr := errors.New("connection refused")
+		f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Any()).Return(expectedErr)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.ImportCars(context.Background(), rows, entities.ImportPartial)
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+	})
+
+	t.Run("partial import stores cars in batches", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		many := make([]entities.ImportRow, importBatchSize+1)
+		for i := range many {
+			many[i] = entities.ImportRow{Row: i + 1, Car: audi}
+		}
+		f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Len(1)).Return(nil).Times(2)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), many, entities.ImportPartial)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, importBatchSize+1, report.Accepted)
+	})
+
+	t.Run("partial import bisects refused batch", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		refused := entities.Car{Brand: "Ford", Model: "Focus", Color: "Green", Cost: 8001}
+		many := make([]entities.ImportRow, 8)
+		for i := range many {
+			many[i] = entities.ImportRow{Row: i + 1, Car: audi}
+		}
+		many[5].Car = refused
+		tooLong := fmt.Errorf("%w: value is too long", entities.ErrValidation)
+		imports := 0
+		f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, batches [][]entities.Car) error {
+			imports++
+			for _, c := range batches[0] {
+				if c == refused {
+					return tooLong
+				}
+			}
+			return nil
+		}).AnyTimes()
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), many, entities.ImportPartial)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 7, report.Accepted)
+		assert.Equal(t, []entities.RejectedRow{{Row: 6, Reason: "validation failed: value is too long"}}, report.Rejected)
+		assert.Equal(t, 7, imports)
+	})
+
+	t.Run("all-or-nothing import stores nothing with rejected rows", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), rows, entities.ImportAllOrNothing)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ImportReport{Rejected: rejected, RolledBack: true}, report)
+	})
+
+	t.Run("all-or-nothing import reports rows refused by repository", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		tooLong := fmt.Errorf("%w: value is too long", entities.ErrValidation)
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		}).Times(3)
+		gomock.InOrder(
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi, ford}}).Return(tooLong),
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi, ford}}).Return(tooLong),
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi}}).Return(nil),
+			f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{ford}}).Return(tooLong),
+		)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), []entities.ImportRow{
+			{Row: 1, Car: audi},
+			{Row: 2, Car: ford},
+		}, entities.ImportAllOrNothing)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ImportReport{
+			Rejected:   []entities.RejectedRow{{Row: 2, Reason: "validation failed: value is too long"}},
+			RolledBack: true,
+		}, report)
+	})
+
+	t.Run("all-or-nothing import fails on repository error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		expectedErr := errors.New("connection refused")
+		f.repository.EXPECT().ImportCars(gomock.Any(), gomock.Any()).Return(expectedErr)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.ImportCars(context.Background(), rows[:1], entities.ImportAllOrNothing)
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+	})
+
+	t.Run("all-or-nothing import stores all batches at once", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.repository.EXPECT().ImportCars(gomock.Any(), [][]entities.Car{{audi, ford}}).Return(nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		report, err := usc.ImportCars(context.Background(), []entities.ImportRow{
+			{Row: 1, Car: audi},
+			{Row: 2, Car: ford},
+		}, entities.ImportAllOrNothing)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ImportReport{Accepted: 2}, report)
+	})
+}
+
+func TestCarsUsecases_BatchCars(t *testing.T) {
+	runInTx := func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	}
+	audi := entities.Car{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}
+	stored := entities.Car{Id: uuid.New(), Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 1}
+	deleted := uuid.New()
+	ops := []entities.CarOperation{
+		{Kind: entities.OperationCreate, Car: audi},
+		{Kind: entities.OperationDelete, Car: entities.Car{Id: deleted, Version: 3}},
+	}
+
+	t.Run("batch commits", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).DoAndReturn(runInTx)
+		f.repository.EXPECT().AddCar(gomock.Any(), audi).Return(stored, nil)
+		f.repository.EXPECT().DeleteCarById(gomock.Any(), deleted, int64(3)).Return(nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		res, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.BatchResult{
+			Committed: true,
+			Results:   []entities.CarOperationResult{{Car: stored}, {}},
+		}, res)
+	})
+
+	t.Run("batch stops at failed operation", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		invalid := []entities.CarOperation{
+			{Kind: entities.OperationUpdate, Car: entities.Car{Id: uuid.New(), Brand: "Audi"}},
+			{Kind: entities.OperationCreate, Car: audi},
+		}
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).DoAndReturn(runInTx)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		res, err := usc.BatchCars(context.Background(), invalid)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.False(t, res.Committed)
+		assert.Len(t, res.Results, 1)
+		assert.ErrorIs(t, res.Results[0].Err, entities.ErrValidation)
+	})
+
+	t.Run("batch retried runs operations again", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				_ = fn(ctx)
+				return fn(ctx)
+			})
+		f.repository.EXPECT().AddCar(gomock.Any(), audi).Return(stored, nil).Times(2)
+		f.repository.EXPECT().DeleteCarById(gomock.Any(), deleted, int64(3)).Return(nil).Times(2)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		res, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, res.Results, len(ops))
+	})
+
+	t.Run("batch fails when transaction gives up retrying", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		serialization := errors.New("could not serialize access")
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				_ = fn(ctx)
+				return fn(ctx)
+			})
+		f.repository.EXPECT().AddCar(gomock.Any(), audi).Return(entities.Car{}, serialization).Times(2)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		res, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.ErrorIs(t, err, serialization)
+		assert.Equal(t, entities.BatchResult{}, res)
+	})
+
+	t.Run("batch fails on commit error", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		expectedErr := errors.New("connection reset")
+		f.tx.EXPECT().RunInTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				if err := fn(ctx); err != nil {
+					return err
+				}
+				return expectedErr
+			})
+		f.repository.EXPECT().AddCar(gomock.Any(), audi).Return(stored, nil)
+		f.repository.EXPECT().DeleteCarById(gomock.Any(), deleted, int64(3)).Return(nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.BatchCars(context.Background(), ops)
+
+		// Assert
+		assert.ErrorIs(t, err, expectedErr)
+	})
+
+	t.Run("batch without operations", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.BatchCars(context.Background(), nil)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrValidation)
+	})
 }
 
 func TestCarsUsecases_DeleteCarById(t *testing.T) {
@@ -148,11 +666,11 @@ func TestCarsUsecases_DeleteCarById(t *testing.T) {
 		// Arrange
 		f := NewFixture(t)
 		id := uuid.New()
-		f.repository.EXPECT().DeleteCarById(gomock.Any(), id).Return(nil)
-		usc := New(f.repository)
+		f.repository.EXPECT().DeleteCarById(gomock.Any(), id, int64(1)).Return(nil)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		err := usc.DeleteCarById(context.Background(), id)
+		err := usc.DeleteCarById(context.Background(), id, 1)
 
 		// Assert
 		assert.NoError(t, err)
@@ -163,17 +681,115 @@ func TestCarsUsecases_DeleteCarById(t *testing.T) {
 		f := NewFixture(t)
 		returnErr := errors.New("text string")
 		id := uuid.New()
-		f.repository.EXPECT().DeleteCarById(gomock.Any(), id).Return(returnErr)
-		usc := New(f.repository)
+		f.repository.EXPECT().DeleteCarById(gomock.Any(), id, int64(1)).Return(returnErr)
+		usc := New(f.repository, f.tx)
 
 		// Act
-		err := usc.DeleteCarById(context.Background(), id)
+		err := usc.DeleteCarById(context.Background(), id, 1)
 
 		// Assert
 		assert.Error(t, returnErr, err)
 	})
 }
 
+func TestCarsUsecases_RestoreCar(t *testing.T) {
+	t.Run("restore car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		car := entities.Car{Id: id, Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000, Version: 3}
+		f.repository.EXPECT().RestoreCar(gomock.Any(), id, int64(2)).Return(car, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		restored, err := usc.RestoreCar(context.Background(), id, 2)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, car, restored)
+	})
+
+	t.Run("restore car that is not deleted", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		f.repository.EXPECT().RestoreCar(gomock.Any(), id, int64(2)).Return(entities.Car{}, entities.ErrConflict)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.RestoreCar(context.Background(), id, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrConflict)
+	})
+}
+
+func TestCarsUsecases_PurgeDeletedCars(t *testing.T) {
+	// Arrange
+	f := NewFixture(t)
+	before := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.repository.EXPECT().PurgeDeletedCars(gomock.Any(), before).Return(int64(2), nil)
+	usc := New(f.repository, f.tx)
+
+	// Act
+	n, err := usc.PurgeDeletedCars(context.Background(), before)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
+func TestCarsUsecases_GetCarHistory(t *testing.T) {
+	t.Run("with revisions", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		revisions := []entities.CarRevision{
+			{CarId: id, Revision: 1, Operation: entities.RevisionInsert, New: &entities.CarValues{Brand: "Audi", Model: "A3", Color: "Red", Cost: 10000}},
+		}
+		f.repository.EXPECT().GetCarRevisions(gomock.Any(), id).Return(revisions, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		history, err := usc.GetCarHistory(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, revisions, history)
+	})
+
+	t.Run("car without revisions", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		f.repository.EXPECT().GetCarRevisions(gomock.Any(), id).Return([]entities.CarRevision{}, nil)
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, true).Return(entities.Car{Id: id}, nil)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		history, err := usc.GetCarHistory(context.Background(), id)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("unknown car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		id := uuid.New()
+		f.repository.EXPECT().GetCarRevisions(gomock.Any(), id).Return([]entities.CarRevision{}, nil)
+		f.repository.EXPECT().GetCarById(gomock.Any(), id, true).Return(entities.Car{}, entities.ErrNotFound)
+		usc := New(f.repository, f.tx)
+
+		// Act
+		_, err := usc.GetCarHistory(context.Background(), id)
+
+		// Assert
+		assert.ErrorIs(t, err, entities.ErrNotFound)
+	})
+}
+
 func TestCarsUsecases_UpdateCar(t *testing.T) {
 	t.Run("update car without error", func(t *testing.T) {
 		// Arrange
@@ -187,7 +803,7 @@ func TestCarsUsecases_UpdateCar(t *testing.T) {
 			Cost:  10000,
 		}
 		f.repository.EXPECT().UpdateCar(gomock.Any(), car).Return(car, nil)
-		usc := New(f.repository)
+		usc := New(f.repository, f.tx)
 
 		// Act
 		reps, err := usc.UpdateCar(context.Background(), car)
@@ -210,7 +826,7 @@ func TestCarsUsecases_UpdateCar(t *testing.T) {
 			Cost:  10000,
 		}
 		f.repository.EXPECT().UpdateCar(gomock.Any(), car).Return(entities.Car{}, returnErr)
-		usc := New(f.repository)
+		usc := New(f.repository, f.tx)
 
 		// Act
 		reps, err := usc.UpdateCar(context.Background(), car)
@@ -219,4 +835,23 @@ func TestCarsUsecases_UpdateCar(t *testing.T) {
 		assert.Equal(t, entities.Car{}, reps)
 		assert.Error(t, returnErr, err)
 	})
+
+	t.Run("update invalid car", func(t *testing.T) {
+		// Arrange
+		f := NewFixture(t)
+		car := entities.Car{
+			Id:    uuid.New(),
+			Brand: "Audi",
+			Model: "A3",
+			Color: "Red",
+		}
+		usc := New(f.repository, f.tx)
+
+		// Act
+		reps, err := usc.UpdateCar(context.Background(), car)
+
+		// Assert
+		assert.Equal(t, entities.Car{}, reps)
+		assert.ErrorIs(t, err, entities.ErrValidation)
+	})
 }
@@ -9,11 +9,28 @@ import (
 
 type Fixture struct {
 	repository *mocks.Mockrepository
+	tx         *mocks.MocktxManager
+	usecases   *mocks.MockcarsUsecases
+	cache      *mocks.Mockcache
+	observer   *mocks.MockcacheObserver
+	apiKeys    *mocks.MockapiKeyRepository
 }
 
 func NewFixture(t *testing.T) *Fixture {
 	mockCtrl := gomock.NewController(t)
 	repoMock := mocks.NewMockrepository(mockCtrl)
+	txMock := mocks.NewMocktxManager(mockCtrl)
+	usecasesMock := mocks.NewMockcarsUsecases(mockCtrl)
+	cacheMock := mocks.NewMockcache(mockCtrl)
+	observerMock := mocks.NewMockcacheObserver(mockCtrl)
+	apiKeysMock := mocks.NewMockapiKeyRepository(mockCtrl)
 
-	return &Fixture{repository: repoMock}
+	return &Fixture{
+		repository: repoMock,
+		tx:         txMock,
+		usecases:   usecasesMock,
+		cache:      cacheMock,
+		observer:   observerMock,
+		apiKeys:    apiKeysMock,
+	}
 }
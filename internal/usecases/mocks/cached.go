@@ -0,0 +1,352 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: cached.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	entities "gihub.com/gibiw/api-example/internal/entities"
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+)
+
+// MockcarsUsecases is a mock of carsUsecases interface.
+type MockcarsUsecases struct {
+	ctrl     *gomock.Controller
+	recorder *MockcarsUsecasesMockRecorder
+}
+
+// MockcarsUsecasesMockRecorder is the mock recorder for MockcarsUsecases.
+type MockcarsUsecasesMockRecorder struct {
+	mock *MockcarsUsecases
+}
+
+// NewMockcarsUsecases creates a new mock instance.
+func NewMockcarsUsecases(ctrl *gomock.Controller) *MockcarsUsecases {
+	mock := &MockcarsUsecases{ctrl: ctrl}
+	mock.recorder = &MockcarsUsecasesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockcarsUsecases) EXPECT() *MockcarsUsecasesMockRecorder {
+	return m.recorder
+}
+
+// AddCar mocks base method.
+func (m *MockcarsUsecases) AddCar(ctx context.Context, car entities.Car) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCar", ctx, car)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCar indicates an expected call of AddCar.
+func (mr *MockcarsUsecasesMockRecorder) AddCar(ctx, car interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCar", reflect.TypeOf((*MockcarsUsecases)(nil).AddCar), ctx, car)
+}
+
+// BatchCars mocks base method.
+func (m *MockcarsUsecases) BatchCars(ctx context.Context, ops []entities.CarOperation) (entities.BatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchCars", ctx, ops)
+	ret0, _ := ret[0].(entities.BatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchCars indicates an expected call of BatchCars.
+func (mr *MockcarsUsecasesMockRecorder) BatchCars(ctx, ops interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchCars", reflect.TypeOf((*MockcarsUsecases)(nil).BatchCars), ctx, ops)
+}
+
+// DeleteCarById mocks base method.
+func (m *MockcarsUsecases) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCarById", ctx, id, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCarById indicates an expected call of DeleteCarById.
+func (mr *MockcarsUsecasesMockRecorder) DeleteCarById(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCarById", reflect.TypeOf((*MockcarsUsecases)(nil).DeleteCarById), ctx, id, version)
+}
+
+// ExportCars mocks base method.
+func (m *MockcarsUsecases) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportCars", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportCars indicates an expected call of ExportCars.
+func (mr *MockcarsUsecasesMockRecorder) ExportCars(ctx, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportCars", reflect.TypeOf((*MockcarsUsecases)(nil).ExportCars), ctx, filter, fn)
+}
+
+// GetCarById mocks base method.
+func (m *MockcarsUsecases) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCarById", ctx, id, includeDeleted)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCarById indicates an expected call of GetCarById.
+func (mr *MockcarsUsecasesMockRecorder) GetCarById(ctx, id, includeDeleted interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarById", reflect.TypeOf((*MockcarsUsecases)(nil).GetCarById), ctx, id, includeDeleted)
+}
+
+// GetCarHistory mocks base method.
+func (m *MockcarsUsecases) GetCarHistory(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCarHistory", ctx, id)
+	ret0, _ := ret[0].([]entities.CarRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCarHistory indicates an expected call of GetCarHistory.
+func (mr *MockcarsUsecasesMockRecorder) GetCarHistory(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarHistory", reflect.TypeOf((*MockcarsUsecases)(nil).GetCarHistory), ctx, id)
+}
+
+// GetCarRevision mocks base method.
+func (m *MockcarsUsecases) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCarRevision", ctx, id, revision)
+	ret0, _ := ret[0].(entities.CarRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCarRevision indicates an expected call of GetCarRevision.
+func (mr *MockcarsUsecasesMockRecorder) GetCarRevision(ctx, id, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarRevision", reflect.TypeOf((*MockcarsUsecases)(nil).GetCarRevision), ctx, id, revision)
+}
+
+// GetCars mocks base method.
+func (m *MockcarsUsecases) GetCars(ctx context.Context, filter entities.CarFilter) (entities.CarsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCars", ctx, filter)
+	ret0, _ := ret[0].(entities.CarsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCars indicates an expected call of GetCars.
+func (mr *MockcarsUsecasesMockRecorder) GetCars(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCars", reflect.TypeOf((*MockcarsUsecases)(nil).GetCars), ctx, filter)
+}
+
+// GetStoredCar mocks base method.
+func (m *MockcarsUsecases) GetStoredCar(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStoredCar", ctx, id)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStoredCar indicates an expected call of GetStoredCar.
+func (mr *MockcarsUsecasesMockRecorder) GetStoredCar(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStoredCar", reflect.TypeOf((*MockcarsUsecases)(nil).GetStoredCar), ctx, id)
+}
+
+// ImportCars mocks base method.
+func (m *MockcarsUsecases) ImportCars(ctx context.Context, rows []entities.ImportRow, mode entities.ImportMode) (entities.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportCars", ctx, rows, mode)
+	ret0, _ := ret[0].(entities.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportCars indicates an expected call of ImportCars.
+func (mr *MockcarsUsecasesMockRecorder) ImportCars(ctx, rows, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportCars", reflect.TypeOf((*MockcarsUsecases)(nil).ImportCars), ctx, rows, mode)
+}
+
+// PurgeDeletedCars mocks base method.
+func (m *MockcarsUsecases) PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedCars", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedCars indicates an expected call of PurgeDeletedCars.
+func (mr *MockcarsUsecasesMockRecorder) PurgeDeletedCars(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedCars", reflect.TypeOf((*MockcarsUsecases)(nil).PurgeDeletedCars), ctx, before)
+}
+
+// RestoreCar mocks base method.
+func (m *MockcarsUsecases) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreCar", ctx, id, version)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreCar indicates an expected call of RestoreCar.
+func (mr *MockcarsUsecasesMockRecorder) RestoreCar(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreCar", reflect.TypeOf((*MockcarsUsecases)(nil).RestoreCar), ctx, id, version)
+}
+
+// UpdateCar mocks base method.
+func (m *MockcarsUsecases) UpdateCar(ctx context.Context, car entities.Car) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCar", ctx, car)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCar indicates an expected call of UpdateCar.
+func (mr *MockcarsUsecasesMockRecorder) UpdateCar(ctx, car interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCar", reflect.TypeOf((*MockcarsUsecases)(nil).UpdateCar), ctx, car)
+}
+
+// Mockcache is a mock of cache interface.
+type Mockcache struct {
+	ctrl     *gomock.Controller
+	recorder *MockcacheMockRecorder
+}
+
+// MockcacheMockRecorder is the mock recorder for Mockcache.
+type MockcacheMockRecorder struct {
+	mock *Mockcache
+}
+
+// NewMockcache creates a new mock instance.
+func NewMockcache(ctrl *gomock.Controller) *Mockcache {
+	mock := &Mockcache{ctrl: ctrl}
+	mock.recorder = &MockcacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockcache) EXPECT() *MockcacheMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *Mockcache) Delete(key string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Delete", key)
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockcacheMockRecorder) Delete(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*Mockcache)(nil).Delete), key)
+}
+
+// Get mocks base method.
+func (m *Mockcache) Get(key string) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", key)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockcacheMockRecorder) Get(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockcache)(nil).Get), key)
+}
+
+// Set mocks base method.
+func (m *Mockcache) Set(key string, value interface{}, ttl time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Set", key, value, ttl)
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockcacheMockRecorder) Set(key, value, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*Mockcache)(nil).Set), key, value, ttl)
+}
+
+// MockcacheObserver is a mock of cacheObserver interface.
+type MockcacheObserver struct {
+	ctrl     *gomock.Controller
+	recorder *MockcacheObserverMockRecorder
+}
+
+// MockcacheObserverMockRecorder is the mock recorder for MockcacheObserver.
+type MockcacheObserverMockRecorder struct {
+	mock *MockcacheObserver
+}
+
+// NewMockcacheObserver creates a new mock instance.
+func NewMockcacheObserver(ctrl *gomock.Controller) *MockcacheObserver {
+	mock := &MockcacheObserver{ctrl: ctrl}
+	mock.recorder = &MockcacheObserverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockcacheObserver) EXPECT() *MockcacheObserverMockRecorder {
+	return m.recorder
+}
+
+// CacheEviction mocks base method.
+func (m *MockcacheObserver) CacheEviction() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CacheEviction")
+}
+
+// CacheEviction indicates an expected call of CacheEviction.
+func (mr *MockcacheObserverMockRecorder) CacheEviction() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheEviction", reflect.TypeOf((*MockcacheObserver)(nil).CacheEviction))
+}
+
+// CacheHit mocks base method.
+func (m *MockcacheObserver) CacheHit() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CacheHit")
+}
+
+// CacheHit indicates an expected call of CacheHit.
+func (mr *MockcacheObserverMockRecorder) CacheHit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheHit", reflect.TypeOf((*MockcacheObserver)(nil).CacheHit))
+}
+
+// CacheMiss mocks base method.
+func (m *MockcacheObserver) CacheMiss() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CacheMiss")
+}
+
+// CacheMiss indicates an expected call of CacheMiss.
+func (mr *MockcacheObserverMockRecorder) CacheMiss() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheMiss", reflect.TypeOf((*MockcacheObserver)(nil).CacheMiss))
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	entities "gihub.com/gibiw/api-example/internal/entities"
 	gomock "github.com/golang/mock/gomock"
@@ -52,47 +53,135 @@ func (mr *MockrepositoryMockRecorder) AddCar(ctx, car interface{}) *gomock.Call
 }
 
 // DeleteCarById mocks base method.
-func (m *Mockrepository) DeleteCarById(ctx context.Context, id uuid.UUID) error {
+func (m *Mockrepository) DeleteCarById(ctx context.Context, id uuid.UUID, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteCarById", ctx, id)
+	ret := m.ctrl.Call(m, "DeleteCarById", ctx, id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteCarById indicates an expected call of DeleteCarById.
-func (mr *MockrepositoryMockRecorder) DeleteCarById(ctx, id interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) DeleteCarById(ctx, id, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCarById", reflect.TypeOf((*Mockrepository)(nil).DeleteCarById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCarById", reflect.TypeOf((*Mockrepository)(nil).DeleteCarById), ctx, id, version)
+}
+
+// ExportCars mocks base method.
+func (m *Mockrepository) ExportCars(ctx context.Context, filter entities.CarFilter, fn func(entities.Car) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportCars", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportCars indicates an expected call of ExportCars.
+func (mr *MockrepositoryMockRecorder) ExportCars(ctx, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportCars", reflect.TypeOf((*Mockrepository)(nil).ExportCars), ctx, filter, fn)
 }
 
 // GetCarById mocks base method.
-func (m *Mockrepository) GetCarById(ctx context.Context, id uuid.UUID) (entities.Car, error) {
+func (m *Mockrepository) GetCarById(ctx context.Context, id uuid.UUID, includeDeleted bool) (entities.Car, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCarById", ctx, id)
+	ret := m.ctrl.Call(m, "GetCarById", ctx, id, includeDeleted)
 	ret0, _ := ret[0].(entities.Car)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCarById indicates an expected call of GetCarById.
-func (mr *MockrepositoryMockRecorder) GetCarById(ctx, id interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) GetCarById(ctx, id, includeDeleted interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarById", reflect.TypeOf((*Mockrepository)(nil).GetCarById), ctx, id, includeDeleted)
+}
+
+// GetCarRevision mocks base method.
+func (m *Mockrepository) GetCarRevision(ctx context.Context, id uuid.UUID, revision int64) (entities.CarRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCarRevision", ctx, id, revision)
+	ret0, _ := ret[0].(entities.CarRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCarRevision indicates an expected call of GetCarRevision.
+func (mr *MockrepositoryMockRecorder) GetCarRevision(ctx, id, revision interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarById", reflect.TypeOf((*Mockrepository)(nil).GetCarById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarRevision", reflect.TypeOf((*Mockrepository)(nil).GetCarRevision), ctx, id, revision)
+}
+
+// GetCarRevisions mocks base method.
+func (m *Mockrepository) GetCarRevisions(ctx context.Context, id uuid.UUID) ([]entities.CarRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCarRevisions", ctx, id)
+	ret0, _ := ret[0].([]entities.CarRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCarRevisions indicates an expected call of GetCarRevisions.
+func (mr *MockrepositoryMockRecorder) GetCarRevisions(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCarRevisions", reflect.TypeOf((*Mockrepository)(nil).GetCarRevisions), ctx, id)
 }
 
 // GetCars mocks base method.
-func (m *Mockrepository) GetCars(ctx context.Context) ([]entities.Car, error) {
+func (m *Mockrepository) GetCars(ctx context.Context, filter entities.CarFilter) ([]entities.Car, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCars", ctx)
+	ret := m.ctrl.Call(m, "GetCars", ctx, filter)
 	ret0, _ := ret[0].([]entities.Car)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCars indicates an expected call of GetCars.
-func (mr *MockrepositoryMockRecorder) GetCars(ctx interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) GetCars(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCars", reflect.TypeOf((*Mockrepository)(nil).GetCars), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCars", reflect.TypeOf((*Mockrepository)(nil).GetCars), ctx, filter)
+}
+
+// ImportCars mocks base method.
+func (m *Mockrepository) ImportCars(ctx context.Context, batches [][]entities.Car) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportCars", ctx, batches)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportCars indicates an expected call of ImportCars.
+func (mr *MockrepositoryMockRecorder) ImportCars(ctx, batches interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportCars", reflect.TypeOf((*Mockrepository)(nil).ImportCars), ctx, batches)
+}
+
+// PurgeDeletedCars mocks base method.
+func (m *Mockrepository) PurgeDeletedCars(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedCars", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedCars indicates an expected call of PurgeDeletedCars.
+func (mr *MockrepositoryMockRecorder) PurgeDeletedCars(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedCars", reflect.TypeOf((*Mockrepository)(nil).PurgeDeletedCars), ctx, before)
+}
+
+// RestoreCar mocks base method.
+func (m *Mockrepository) RestoreCar(ctx context.Context, id uuid.UUID, version int64) (entities.Car, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreCar", ctx, id, version)
+	ret0, _ := ret[0].(entities.Car)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreCar indicates an expected call of RestoreCar.
+func (mr *MockrepositoryMockRecorder) RestoreCar(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreCar", reflect.TypeOf((*Mockrepository)(nil).RestoreCar), ctx, id, version)
 }
 
 // UpdateCar mocks base method.
@@ -109,3 +198,40 @@ func (mr *MockrepositoryMockRecorder) UpdateCar(ctx, car interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCar", reflect.TypeOf((*Mockrepository)(nil).UpdateCar), ctx, car)
 }
+
+// MocktxManager is a mock of txManager interface.
+type MocktxManager struct {
+	ctrl     *gomock.Controller
+	recorder *MocktxManagerMockRecorder
+}
+
+// MocktxManagerMockRecorder is the mock recorder for MocktxManager.
+type MocktxManagerMockRecorder struct {
+	mock *MocktxManager
+}
+
+// NewMocktxManager creates a new mock instance.
+func NewMocktxManager(ctrl *gomock.Controller) *MocktxManager {
+	mock := &MocktxManager{ctrl: ctrl}
+	mock.recorder = &MocktxManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocktxManager) EXPECT() *MocktxManagerMockRecorder {
+	return m.recorder
+}
+
+// RunInTx mocks base method.
+func (m *MocktxManager) RunInTx(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunInTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunInTx indicates an expected call of RunInTx.
+func (mr *MocktxManagerMockRecorder) RunInTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunInTx", reflect.TypeOf((*MocktxManager)(nil).RunInTx), ctx, fn)
+}
@@ -0,0 +1,47 @@
+package usecases
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+)
+
+const (
+	// maxNameLength matches the varchar(50) columns of the cars table.
+	maxNameLength = 50
+	minCost       = 1
+	maxCost       = 1_000_000_000
+)
+
+func validateCar(car entities.Car) error {
+	fields := []entities.FieldError{}
+	checkName := func(field, value string) {
+		switch {
+		case value == "":
+			fields = append(fields, entities.FieldError{Field: field, Message: "is required"})
+		case utf8.RuneCountInString(value) > maxNameLength:
+			fields = append(fields, entities.FieldError{
+				Field:   field,
+				Message: fmt.Sprintf("must be at most %d characters long", maxNameLength),
+			})
+		}
+	}
+
+	checkName("brand", car.Brand)
+	checkName("model", car.Model)
+	checkName("color", car.Color)
+
+	if car.Cost < minCost || car.Cost > maxCost {
+		fields = append(fields, entities.FieldError{
+			Field:   "cost",
+			Message: fmt.Sprintf("must be between %d and %d", minCost, maxCost),
+		})
+	}
+
+	if len(fields) > 0 {
+		return &entities.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+
+	"gihub.com/gibiw/api-example/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCar(t *testing.T) {
+	valid := entities.Car{
+		Brand: "Audi",
+		Model: "A3",
+		Color: "Red",
+		Cost:  10000,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *entities.Car)
+		fields []string
+	}{
+		{
+			name:   "valid car",
+			modify: func(c *entities.Car) {},
+		},
+		{
+			name:   "empty names",
+			modify: func(c *entities.Car) { c.Brand, c.Model, c.Color = "", "", "" },
+			fields: []string{"brand", "model", "color"},
+		},
+		{
+			name:   "too long brand",
+			modify: func(c *entities.Car) { c.Brand = strings.Repeat("a", maxNameLength+1) },
+			fields: []string{"brand"},
+		},
+		{
+			name:   "multibyte brand of maximal length",
+			modify: func(c *entities.Car) { c.Brand = strings.Repeat("ё", maxNameLength) },
+		},
+		{
+			name:   "zero cost",
+			modify: func(c *entities.Car) { c.Cost = 0 },
+			fields: []string{"cost"},
+		},
+		{
+			name:   "too high cost",
+			modify: func(c *entities.Car) { c.Cost = maxCost + 1 },
+			fields: []string{"cost"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			car := valid
+			tt.modify(&car)
+
+			// Act
+			err := validateCar(car)
+
+			// Assert
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, entities.ErrValidation)
+			validationErr := &entities.ValidationError{}
+			assert.ErrorAs(t, err, &validationErr)
+			fields := []string{}
+			for _, f := range validationErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
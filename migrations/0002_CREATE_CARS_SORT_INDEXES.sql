@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS cars_brand_id_idx ON cars (brand, id);
+CREATE INDEX IF NOT EXISTS cars_model_id_idx ON cars (model, id);
+CREATE INDEX IF NOT EXISTS cars_color_id_idx ON cars (color, id);
+CREATE INDEX IF NOT EXISTS cars_cost_id_idx ON cars (cost, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS cars_cost_id_idx;
+DROP INDEX IF EXISTS cars_color_id_idx;
+DROP INDEX IF EXISTS cars_model_id_idx;
+DROP INDEX IF EXISTS cars_brand_id_idx;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    name varchar (50) NOT NULL,
+    prefix varchar (16) NOT NULL,
+    key_hash char (64) NOT NULL,
+    role varchar (16) NOT NULL CHECK (role IN ('reader', 'editor', 'admin')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz,
+    PRIMARY KEY(id),
+    UNIQUE(key_hash)
+);
+
+-- +goose Down
+DROP TABLE api_keys;
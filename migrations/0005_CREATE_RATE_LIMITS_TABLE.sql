@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key varchar (200) NOT NULL,
+    tokens double precision NOT NULL,
+    updated_at timestamptz NOT NULL,
+    PRIMARY KEY(key)
+);
+
+-- +goose Down
+DROP TABLE rate_limits;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS car_revisions (
+    car_id uuid NOT NULL,
+    revision bigint NOT NULL,
+    operation varchar (16) NOT NULL CHECK (operation IN ('insert', 'update', 'delete', 'restore', 'purge')),
+    old_values jsonb,
+    new_values jsonb,
+    actor text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT '',
+    changed_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(car_id, revision)
+);
+
+-- +goose Down
+DROP TABLE car_revisions;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox_events (
+    seq bigserial NOT NULL,
+    id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    type varchar (32) NOT NULL,
+    car_id uuid NOT NULL,
+    payload jsonb NOT NULL,
+    occurred_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(seq),
+    UNIQUE(id)
+);
+
+-- +goose Down
+DROP TABLE outbox_events;
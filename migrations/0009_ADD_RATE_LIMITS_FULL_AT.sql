@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE rate_limits ADD COLUMN IF NOT EXISTS full_at timestamptz NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS rate_limits_full_at_idx ON rate_limits (full_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS rate_limits_full_at_idx;
+ALTER TABLE rate_limits DROP COLUMN IF EXISTS full_at;
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const migrationsQuery = "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC"
+
+// MigrationVersion returns the schema version recorded by goose. Like goose
+// itself it walks the log backwards and skips versions that were rolled back.
+func MigrationVersion(ctx context.Context, db *sqlx.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, migrationsQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	rolledBack := map[int64]bool{}
+	for rows.Next() {
+		var (
+			version int64
+			applied bool
+		)
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, err
+		}
+
+		if rolledBack[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		rolledBack[version] = true
+	}
+
+	return 0, rows.Err()
+}
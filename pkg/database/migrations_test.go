@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationVersion(t *testing.T) {
+	t.Run("skips rolled back versions", func(t *testing.T) {
+		// Arrange
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+		rows := sqlmock.NewRows([]string{"version_id", "is_applied"}).
+			AddRow(3, false).
+			AddRow(3, true).
+			AddRow(2, true).
+			AddRow(1, true)
+		mock.ExpectQuery(regexp.QuoteMeta(migrationsQuery)).WillReturnRows(rows)
+
+		// Act
+		version, err := MigrationVersion(context.Background(), sqlx.NewDb(mockDB, "sqlmock"))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), version)
+	})
+
+	t.Run("without migrations", func(t *testing.T) {
+		// Arrange
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+		mock.ExpectQuery(regexp.QuoteMeta(migrationsQuery)).
+			WillReturnRows(sqlmock.NewRows([]string{"version_id", "is_applied"}))
+
+		// Act
+		version, err := MigrationVersion(context.Background(), sqlx.NewDb(mockDB, "sqlmock"))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), version)
+	})
+}